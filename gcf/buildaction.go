@@ -0,0 +1,126 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// Action IDs of interactive buttons on messages of builds.
+const (
+	RetryBuildAction  = "retry_build"
+	CancelBuildAction = "cancel_build"
+)
+
+// BuildController retries and cancels builds of Cloud Build.
+type BuildController interface {
+	// Retry starts a new build from the build and returns its ID.
+	Retry(ctx context.Context, projectID, buildID string) (string, error)
+	Cancel(ctx context.Context, projectID, buildID string) error
+}
+
+// CloudBuildController calls the API of Cloud Build.
+type CloudBuildController struct {
+	builds *cloudbuild.ProjectsBuildsService
+}
+
+func NewCloudBuildController(ctx context.Context) (*CloudBuildController, error) {
+	client, err := google.DefaultClient(ctx, cloudbuild.CloudPlatformScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a Google client")
+	}
+
+	svc, err := cloudbuild.New(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create Cloud Build service")
+	}
+	return &CloudBuildController{builds: cloudbuild.NewProjectsBuildsService(svc)}, nil
+}
+
+func (c *CloudBuildController) Retry(ctx context.Context, projectID, buildID string) (string, error) {
+	op, err := c.builds.Retry(projectID, buildID, &cloudbuild.RetryBuildRequest{}).Context(ctx).Do()
+	if err != nil {
+		return "", errors.Wrapf(classifyGoogleAPIError(err), "Failed to retry %s", buildID)
+	}
+
+	// The metadata of the operation is BuildOperationMetadata which has the new build.
+	var meta struct {
+		Build struct {
+			ID string `json:"id"`
+		} `json:"build"`
+	}
+	if err := json.Unmarshal(op.Metadata, &meta); err != nil {
+		return "", errors.Wrapf(err, "Failed to decode the operation retrying %s", buildID)
+	}
+	return meta.Build.ID, nil
+}
+
+func (c *CloudBuildController) Cancel(ctx context.Context, projectID, buildID string) error {
+	_, err := c.builds.Cancel(projectID, buildID, &cloudbuild.CancelBuildRequest{}).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(classifyGoogleAPIError(err), "Failed to cancel %s", buildID)
+	}
+	return nil
+}
+
+// buildActionValue identifies the build of a button as "<project>/<build>/<language>",
+// so that the message is updated in the language of it.
+func buildActionValue(lang, projectID, buildID string) string {
+	return projectID + "/" + buildID + "/" + lang
+}
+
+// parseBuildActionValue returns English for "<project>/<build>" of buttons posted without languages.
+func parseBuildActionValue(v string) (string, string, string, error) {
+	ps := strings.Split(v, "/")
+	if len(ps) == 2 {
+		ps = append(ps, English)
+	}
+	if len(ps) != 3 || ps[0] == "" || ps[1] == "" || !validLanguage(ps[2]) {
+		return "", "", "", errors.Errorf("%s is not a build", v)
+	}
+	return ps[0], ps[1], ps[2], nil
+}
+
+func retryButton(lang, projectID, buildID string) SlackButton {
+	return SlackButton{
+		Type:     "button",
+		Text:     plainText(translate(lang, "Retry Build")),
+		ActionID: RetryBuildAction,
+		Value:    buildActionValue(lang, projectID, buildID),
+		Style:    "primary",
+	}
+}
+
+func cancelButton(lang, projectID, buildID string) SlackButton {
+	return SlackButton{
+		Type:     "button",
+		Text:     plainText(translate(lang, "Cancel Build")),
+		ActionID: CancelBuildAction,
+		Value:    buildActionValue(lang, projectID, buildID),
+		Style:    "danger",
+	}
+}
+
+// buildActionButtons returns Retry for builds which didn't succeed and Cancel for builds in progress,
+// only if Slack can call the interaction endpoint.
+func buildActionButtons(b BuildEvent, c *SlackConfig) []interface{} {
+	if !c.Interactive() {
+		return nil
+	}
+	switch {
+	case isFailure(b.Status) || b.Status == "CANCELLED" || b.Status == "EXPIRED":
+		return []interface{}{retryButton(c.language(), b.ProjectID, b.ID)}
+	case b.IsInProgress():
+		return []interface{}{cancelButton(c.language(), b.ProjectID, b.ID)}
+	}
+	return nil
+}
+
+func buildConsoleURL(projectID, buildID string) string {
+	return fmt.Sprintf("https://console.cloud.google.com/cloud-build/builds/%s?project=%s", buildID, projectID)
+}
@@ -0,0 +1,66 @@
+package gcf
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildActionButtons(t *testing.T) {
+	interactive := &SlackConfig{SlackSigningSecret: "secret"}
+	tests := []struct {
+		name   string
+		status string
+		config *SlackConfig
+		want   []interface{}
+	}{
+		{"Failure", "FAILURE", interactive, []interface{}{retryButton(English, "nomos-sms", "build-id")}},
+		{"Timeout", "TIMEOUT", interactive, []interface{}{retryButton(English, "nomos-sms", "build-id")}},
+		{"Cancelled", "CANCELLED", interactive, []interface{}{retryButton(English, "nomos-sms", "build-id")}},
+		{"Working", "WORKING", interactive, []interface{}{cancelButton(English, "nomos-sms", "build-id")}},
+		{"Queued", "QUEUED", interactive, []interface{}{cancelButton(English, "nomos-sms", "build-id")}},
+		{"Success", "SUCCESS", interactive, nil},
+		{"Without signing secret", "FAILURE", &SlackConfig{}, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{ID: "build-id", ProjectID: "nomos-sms", Status: tt.status}
+			got := buildActionButtons(e, tt.config)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("buildActionButtons() = %v, want %v, differs: (-got +want;\n%s)", got, tt.want, diff)
+			}
+		})
+	}
+}
+
+func TestParseBuildActionValue(t *testing.T) {
+	tests := []struct {
+		value       string
+		wantProject string
+		wantBuild   string
+		wantLang    string
+		wantErr     bool
+	}{
+		{"nomos-sms/build-id/ja", "nomos-sms", "build-id", Japanese, false},
+		{"nomos-sms/build-id", "nomos-sms", "build-id", English, false},
+		{"build-id", "", "", "", true},
+		{"nomos-sms/", "", "", "", true},
+		{"nomos-sms/build-id/fr", "", "", "", true},
+		{"a/b/c/d", "", "", "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			project, build, lang, err := parseBuildActionValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBuildActionValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if project != tt.wantProject || build != tt.wantBuild || lang != tt.wantLang {
+				t.Errorf("parseBuildActionValue() = %s, %s, %s, want %s, %s, %s", project, build, lang, tt.wantProject, tt.wantBuild, tt.wantLang)
+			}
+		})
+	}
+}
@@ -3,16 +3,22 @@ package gcf
 //nolint[:gosec]
 import (
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
+	"path"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	TagDeployDefault = "deploy-default-service"
 	TagDeployAdmin   = "deploy-admin-service"
+	// RepositoryURL is the repository of builds unless REPOSITORY_URL is given.
 	RepositoryURL    = "https://github.com/bm-sms/nomos"
 	MaxVersionLength = 40
+	ShortSHALength   = 7
 )
 
 type SlackStatus struct {
@@ -20,13 +26,25 @@ type SlackStatus struct {
 	Icon  string
 }
 
+// statusMap has how every status of Cloud Build looks on Slack.
 var statusMap = map[string]SlackStatus{
 	"SUCCESS":        {Color: "#2aa24b", Icon: ":white_check_mark:"},
 	"FAILURE":        {Color: "#d50200", Icon: ":x:"},
 	"INTERNAL_ERROR": {Color: "#d50200", Icon: ":sos:"},
 	"TIMEOUT":        {Color: "#de9d2e", Icon: ":sos:"},
+	"CANCELLED":      {Color: "#9e9e9e", Icon: ":no_entry_sign:"},
+	"EXPIRED":        {Color: "#9e9e9e", Icon: ":hourglass:"},
+	"PENDING":        {Color: "#de9d2e", Icon: ":raised_hand:"},
+	"QUEUED":         {Color: "#a0a0a0", Icon: ":hourglass_flowing_sand:"},
+	"WORKING":        {Color: "#3aa3e3", Icon: ":hammer_and_wrench:"},
 }
 
+// DefaultNotifyStatuses are notified unless other statuses are configured.
+var DefaultNotifyStatuses = []string{"SUCCESS", "FAILURE", "INTERNAL_ERROR", "TIMEOUT"}
+
+// inProgressStatuses come before the result of a build.
+var inProgressStatuses = []string{"PENDING", "QUEUED", "WORKING"}
+
 type BuildEvent struct {
 	ID             string              `json:"id"`
 	ProjectID      string              `json:"projectId"`
@@ -41,20 +59,122 @@ type BuildEvent struct {
 	LogURL         string              `json:"logUrl"`
 	Tags           *BuildTags          `json:"tags"`
 	Substitutions  *BuildSubstitutions `json:"substitutions"`
+	Steps          []BuildStep         `json:"steps"`
+	// Streak is set from the history of the branch, not by Cloud Build.
+	Streak *BuildStreak `json:"-"`
+	// LogExcerpt is read from the log in LogsBucket on failure.
+	LogExcerpt []string `json:"-"`
 }
 
+// BuildSubstitutions has substitutions of Cloud Build.
+// See https://cloud.google.com/cloud-build/docs/configuring-builds/substitute-variable-values
 type BuildSubstitutions struct {
-	BranchName string `json:"BRANCH_NAME"`
+	BranchName  string `json:"BRANCH_NAME"`
+	CommitSHA   string `json:"COMMIT_SHA"`
+	ShortSHA    string `json:"SHORT_SHA"`
+	TagName     string `json:"TAG_NAME"`
+	RepoName    string `json:"REPO_NAME"`
+	RevisionID  string `json:"REVISION_ID"`
+	TriggerName string `json:"TRIGGER_NAME"`
+	// Pull request triggers of GitHub have them.
+	PRNumber   string `json:"_PR_NUMBER"`
+	HeadBranch string `json:"_HEAD_BRANCH"`
+	BaseBranch string `json:"_BASE_BRANCH"`
+	// Custom has user-defined substitutions which start with "_".
+	Custom map[string]string `json:"-"`
 }
 
+func (s *BuildSubstitutions) UnmarshalJSON(b []byte) error {
+	type builtin BuildSubstitutions
+	if err := json.Unmarshal(b, (*builtin)(s)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	all := map[string]string{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return errors.WithStack(err)
+	}
+	for k, v := range all {
+		switch k {
+		case "_PR_NUMBER", "_HEAD_BRANCH", "_BASE_BRANCH":
+			continue
+		}
+		if strings.HasPrefix(k, "_") {
+			if s.Custom == nil {
+				s.Custom = map[string]string{}
+			}
+			s.Custom[k] = v
+		}
+	}
+	return nil
+}
+
+// BuildSource is where the source of a build is. Only one of them is set,
+// and none of them for builds without sources such as "gcloud builds submit --no-source".
+// See https://cloud.google.com/build/docs/api/reference/rest/v1/projects.builds#Source
 type BuildSource struct {
-	RepoSource *BuildRepoSource
+	StorageSource       *BuildStorageSource       `json:"storageSource"`
+	RepoSource          *BuildRepoSource          `json:"repoSource"`
+	GitSource           *BuildGitSource           `json:"gitSource"`
+	ConnectedRepository *BuildConnectedRepository `json:"connectedRepository"`
+	DeveloperConnect    *BuildDeveloperConnect    `json:"developerConnectConfig"`
+}
+
+// BuildStorageSource is an archive uploaded to Cloud Storage, such as by "gcloud builds submit".
+type BuildStorageSource struct {
+	Bucket     string `json:"bucket"`
+	Object     string `json:"object"`
+	Generation string `json:"generation"`
 }
 
+// BuildRepoSource is a repository of Cloud Source Repositories.
 type BuildRepoSource struct {
 	ProjectID  string `json:"projectId"`
 	RepoName   string `json:"repoName"`
 	BranchName string `json:"branchName"`
+	TagName    string `json:"tagName"`
+	CommitSHA  string `json:"commitSha"`
+	Dir        string `json:"dir"`
+}
+
+// BuildGitSource is a Git repository fetched with its URL.
+type BuildGitSource struct {
+	URL      string `json:"url"`
+	Dir      string `json:"dir"`
+	Revision string `json:"revision"`
+}
+
+// BuildStep is a step of a build defined in cloudbuild.yaml.
+type BuildStep struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Entrypoint string    `json:"entrypoint"`
+	Args       []string  `json:"args"`
+	Status     string    `json:"status"`
+	Timing     *TimeSpan `json:"timing"`
+}
+
+type TimeSpan struct {
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+// BuildConnectedRepository is a repository connected to Cloud Build as 2nd gen.
+type BuildConnectedRepository struct {
+	// Repository is a resource name such as
+	// projects/<project>/locations/<location>/connections/<connection>/repositories/<repo>.
+	Repository string `json:"repository"`
+	Dir        string `json:"dir"`
+	Revision   string `json:"revision"`
+}
+
+// BuildDeveloperConnect is a repository linked with Developer Connect.
+type BuildDeveloperConnect struct {
+	// GitRepositoryLink is a resource name such as
+	// projects/<project>/locations/<location>/connections/<connection>/gitRepositoryLinks/<link>.
+	GitRepositoryLink string `json:"gitRepositoryLink"`
+	Dir               string `json:"dir"`
+	Revision          string `json:"revision"`
 }
 
 type BuildTags []string
@@ -66,73 +186,214 @@ type AppURL struct {
 
 type RepositoryBranch string
 
-func (e BuildEvent) AvailableStatus() bool {
-	_, ok := statusMap[e.Status]
-	return ok
+func (e BuildEvent) IsInProgress() bool {
+	return isInProgress(e.Status)
 }
 
-func (e BuildEvent) SlackStatus() SlackStatus {
-	return statusMap[e.Status]
+// HasSource reports whether the build comes from any source, including ones uploaded manually.
+func (e BuildEvent) HasSource() bool {
+	return e.Source != nil || e.Origin() != ""
 }
 
-func (e BuildEvent) HasSource() bool {
-	return e.Source != nil
+// Origin describes where the source of the build comes from, such as "nomos@main" or
+// "gs://<bucket>/<object>" for an upload of "gcloud builds submit".
+// It returns an empty string for builds without sources.
+func (e BuildEvent) Origin() string {
+	var s BuildSource
+	if e.Source != nil {
+		s = *e.Source
+	}
+	switch {
+	case s.ConnectedRepository != nil:
+		return revisionOf(path.Base(s.ConnectedRepository.Repository), s.ConnectedRepository.Revision)
+	case s.DeveloperConnect != nil:
+		return revisionOf(path.Base(s.DeveloperConnect.GitRepositoryLink), s.DeveloperConnect.Revision)
+	case s.GitSource != nil:
+		return revisionOf(strings.TrimSuffix(s.GitSource.URL, ".git"), s.GitSource.Revision)
+	case s.RepoSource != nil:
+		r := s.RepoSource
+		rev := r.BranchName
+		if rev == "" {
+			rev = r.TagName
+		}
+		if rev == "" {
+			rev = r.CommitSHA
+		}
+		return revisionOf(r.RepoName, rev)
+	case s.StorageSource != nil:
+		return fmt.Sprintf("gs://%s/%s", s.StorageSource.Bucket, s.StorageSource.Object)
+	}
+	// Some triggers build repositories without sources and tell them only by substitutions.
+	if e.Substitutions != nil && e.Substitutions.RepoName != "" {
+		rev := e.Substitutions.BranchName
+		if rev == "" {
+			rev = e.Substitutions.TagName
+		}
+		return revisionOf(e.Substitutions.RepoName, rev)
+	}
+	return ""
+}
+
+func revisionOf(name, revision string) string {
+	if revision == "" {
+		return name
+	}
+	return name + "@" + strings.TrimPrefix(revision, "refs/heads/")
 }
 
 func (e BuildEvent) IsSuccess() bool {
 	return e.Status == "SUCCESS"
 }
 
+// Branch returns an empty string for builds which aren't from branches, such as uploaded sources.
 func (e BuildEvent) Branch() RepositoryBranch {
-	var br string
 	// Cloud Build Github App has branch name in substitutions
 	if e.Substitutions != nil && e.Substitutions.BranchName != "" {
-		br = e.Substitutions.BranchName
-	} else {
-		br = e.Source.RepoSource.BranchName
+		return RepositoryBranch(e.Substitutions.BranchName)
+	}
+	if e.Source == nil {
+		return ""
+	}
+
+	var rev string
+	switch s := e.Source; {
+	case s.RepoSource != nil:
+		return RepositoryBranch(s.RepoSource.BranchName)
+	case s.ConnectedRepository != nil:
+		rev = s.ConnectedRepository.Revision
+	case s.DeveloperConnect != nil:
+		rev = s.DeveloperConnect.Revision
+	case s.GitSource != nil:
+		rev = s.GitSource.Revision
 	}
+	// Other revisions can be commits or tags.
+	if !strings.HasPrefix(rev, "refs/heads/") {
+		return ""
+	}
+	return RepositoryBranch(strings.TrimPrefix(rev, "refs/heads/"))
+}
 
-	return RepositoryBranch(br)
+// CommitSHA returns COMMIT_SHA, or REVISION_ID if the build isn't from a commit.
+func (e BuildEvent) CommitSHA() string {
+	if e.Substitutions == nil {
+		return ""
+	}
+	if e.Substitutions.CommitSHA != "" {
+		return e.Substitutions.CommitSHA
+	}
+	return e.Substitutions.RevisionID
 }
 
-func (e BuildEvent) IsDeploy() bool {
-	return e.Tags != nil && (e.Tags.includedTag(TagDeployDefault) || e.Tags.includedTag(TagDeployAdmin))
+func (e BuildEvent) ShortSHA() string {
+	if e.Substitutions != nil && e.Substitutions.ShortSHA != "" {
+		return e.Substitutions.ShortSHA
+	}
+	sha := e.CommitSHA()
+	if len(sha) > ShortSHALength {
+		return sha[:ShortSHALength]
+	}
+	return sha
 }
 
-func (e BuildEvent) AppURLs(c *SlackConfig) []AppURL {
-	if e.Tags.includedTag(TagDeployDefault) {
-		return []AppURL{
-			{Title: "SMS URL", URL: e.defaultURL(c)},
-			{Title: "SMS Career URL", URL: e.careerURL(c)},
-		}
+// PullRequest returns the number of the pull request, or an empty string for other builds.
+func (e BuildEvent) PullRequest() string {
+	if e.Substitutions == nil {
+		return ""
 	}
+	return e.Substitutions.PRNumber
+}
 
-	return []AppURL{
-		{Title: "Admin URL", URL: e.adminURL(c)},
+func (e BuildEvent) GitTag() string {
+	if e.Substitutions == nil {
+		return ""
 	}
+	return e.Substitutions.TagName
 }
 
-func (e BuildEvent) defaultURL(c *SlackConfig) string {
-	return e.domainToURL(c.DefaultDomain())
+func (e BuildEvent) TriggerName() string {
+	if e.Substitutions == nil {
+		return ""
+	}
+	return e.Substitutions.TriggerName
 }
 
-func (e BuildEvent) careerURL(c *SlackConfig) string {
-	return e.domainToURL(c.CareerDomain())
+// FailedStep returns the first step which failed or timed out, or nil if no steps failed.
+func (e BuildEvent) FailedStep() *BuildStep {
+	for i, s := range e.Steps {
+		switch s.Status {
+		case "FAILURE", "INTERNAL_ERROR", "TIMEOUT":
+			return &e.Steps[i]
+		}
+	}
+	return nil
 }
 
-func (e BuildEvent) adminURL(c *SlackConfig) string {
-	return e.domainToURL(c.AdminDomain())
+// StepDuration returns how long the step ran.
+// A step which timed out has no end time, so the build is regarded as the end of it.
+func (e BuildEvent) StepDuration(s *BuildStep) time.Duration {
+	if s.Timing == nil || s.Timing.StartTime.IsZero() {
+		return 0
+	}
+	end := s.Timing.EndTime
+	if end.IsZero() {
+		end = e.FinishTime
+	}
+	if end.Before(s.Timing.StartTime) {
+		return 0
+	}
+	return end.Sub(s.Timing.StartTime)
 }
 
-func (e BuildEvent) domainToURL(domain string) string {
-	if e.Branch().isMaster() {
-		return fmt.Sprintf("https://%s", domain)
+// String returns the id of the step with its builder image.
+func (s BuildStep) String() string {
+	if s.ID == "" {
+		return s.Name
 	}
-	return fmt.Sprintf("https://%s-dot-%s", e.Branch().ToVersion(), domain)
+	return fmt.Sprintf("%s (%s)", s.ID, s.Name)
 }
 
-func (b RepositoryBranch) URL() string {
-	return fmt.Sprintf("%s/tree/%s", RepositoryURL, b)
+// IsDeploy reports whether the build has any tags of deploys in c.
+func (e BuildEvent) IsDeploy(c *SlackConfig) bool {
+	if e.Tags == nil {
+		return false
+	}
+	deploys := c.deploys()
+	for _, t := range *e.Tags {
+		if _, ok := deploys[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AppURLs returns URLs of apps deployed by the build in the order of its tags.
+func (e BuildEvent) AppURLs(c *SlackConfig) []AppURL {
+	if e.Tags == nil {
+		return nil
+	}
+
+	deploys := c.deploys()
+	var urls []AppURL
+	for _, t := range *e.Tags {
+		for _, d := range deploys[t] {
+			u, err := d.render(DeployURLParams{
+				Branch:  e.Branch(),
+				Version: e.Branch().ToVersion(),
+				Project: c.ProjectID,
+				Service: d.Service,
+				Domain:  c.DefaultDomain(),
+			})
+			if err != nil {
+				defaultLogger.Error(err, "Failed to render URL of "+d.Title)
+				continue
+			}
+			if strings.TrimSpace(u) == "" {
+				continue
+			}
+			urls = append(urls, AppURL{Title: d.Title, URL: u})
+		}
+	}
+	return urls
 }
 
 //nolint[:gosec]
@@ -145,10 +406,6 @@ func (b RepositoryBranch) ToVersion() string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(v)))
 }
 
-func (b RepositoryBranch) isMaster() bool {
-	return string(b) == "master"
-}
-
 func (tags *BuildTags) includedTag(tag string) bool {
 	for _, t := range []string(*tags) {
 		if t == tag {
@@ -157,3 +414,16 @@ func (tags *BuildTags) includedTag(tag string) bool {
 	}
 	return false
 }
+
+func isInProgress(status string) bool {
+	return includedStatus(inProgressStatuses, status)
+}
+
+func includedStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -1,23 +1,23 @@
 package gcf
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
 
-func TestBuildEvent_AvailableStatus(t *testing.T) {
+func TestBuildEvent_IsInProgress(t *testing.T) {
 	tests := []struct {
 		status string
 		want   bool
 	}{
-		{"SUCCESS", true},
-		{"FAILURE", true},
-		{"INTERNAL_ERROR", true},
-		{"TIMEOUT", true},
-		{"QUEUED", false},
-		{"WORKING", false},
+		{"PENDING", true},
+		{"QUEUED", true},
+		{"WORKING", true},
+		{"SUCCESS", false},
 		{"CANCELLED", false},
 	}
 	for _, tt := range tests {
@@ -27,33 +27,8 @@ func TestBuildEvent_AvailableStatus(t *testing.T) {
 			e := BuildEvent{
 				Status: tt.status,
 			}
-			if got := e.AvailableStatus(); got != tt.want {
-				t.Errorf("BuildEvent.AvailableStatus() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestBuildEvent_SlackStatus(t *testing.T) {
-	tests := []struct {
-		status string
-		want   SlackStatus
-	}{
-		{"SUCCESS", SlackStatus{Color: "#2aa24b", Icon: ":white_check_mark:"}},
-		{"FAILURE", SlackStatus{Color: "#d50200", Icon: ":x:"}},
-		{"INTERNAL_ERROR", SlackStatus{Color: "#d50200", Icon: ":sos:"}},
-		{"TIMEOUT", SlackStatus{Color: "#de9d2e", Icon: ":sos:"}},
-	}
-	for _, tt := range tests {
-		tt := tt
-		t.Run(tt.status, func(t *testing.T) {
-			t.Parallel()
-			e := BuildEvent{
-				Status: tt.status,
-			}
-			got := e.SlackStatus()
-			if diff := cmp.Diff(got, tt.want); diff != "" {
-				t.Errorf("BuildEvent.SlackStatus() = %v, want %v, differs: (-got +want;\n%s)", got, tt.want, diff)
+			if got := e.IsInProgress(); got != tt.want {
+				t.Errorf("BuildEvent.IsInProgress() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -65,7 +40,8 @@ func TestBuildEvent_HasSource(t *testing.T) {
 		source *BuildSource
 		want   bool
 	}{
-		{"Has an available source", &BuildSource{&BuildRepoSource{BranchName: "master"}}, true},
+		{"Has an available source", &BuildSource{RepoSource: &BuildRepoSource{BranchName: "master"}}, true},
+		{"Uploaded source", &BuildSource{StorageSource: &BuildStorageSource{Bucket: "nomos-sms_cloudbuild", Object: "source/1550106000.tgz"}}, true},
 		{"Has no available sources", nil, false},
 	}
 	for _, tt := range tests {
@@ -116,21 +92,45 @@ func TestBuildEvent_Branch(t *testing.T) {
 		{
 			"When using Cloud Build Github App",
 			&BuildSubstitutions{BranchName: "master"},
-			&BuildSource{&BuildRepoSource{BranchName: "dev"}},
+			&BuildSource{RepoSource: &BuildRepoSource{BranchName: "dev"}},
 			RepositoryBranch("master"),
 		},
 		{
 			"Include substitutions field, but don't have branch name",
 			&BuildSubstitutions{},
-			&BuildSource{&BuildRepoSource{BranchName: "dev"}},
+			&BuildSource{RepoSource: &BuildRepoSource{BranchName: "dev"}},
 			RepositoryBranch("dev"),
 		},
 		{
 			"Not include any substitutions",
 			nil,
-			&BuildSource{&BuildRepoSource{BranchName: "dev"}},
+			&BuildSource{RepoSource: &BuildRepoSource{BranchName: "dev"}},
 			RepositoryBranch("dev"),
 		},
+		{
+			"Uploaded source",
+			nil,
+			&BuildSource{StorageSource: &BuildStorageSource{Bucket: "nomos-sms_cloudbuild", Object: "source/1550106000.tgz"}},
+			RepositoryBranch(""),
+		},
+		{
+			"Connected repository at a branch",
+			nil,
+			&BuildSource{ConnectedRepository: &BuildConnectedRepository{Revision: "refs/heads/main"}},
+			RepositoryBranch("main"),
+		},
+		{
+			"Git source at a commit",
+			nil,
+			&BuildSource{GitSource: &BuildGitSource{Revision: "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c"}},
+			RepositoryBranch(""),
+		},
+		{
+			"No sources",
+			nil,
+			nil,
+			RepositoryBranch(""),
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -147,6 +147,71 @@ func TestBuildEvent_Branch(t *testing.T) {
 	}
 }
 
+func TestBuildEvent_Origin(t *testing.T) {
+	tests := []struct {
+		name   string
+		sub    *BuildSubstitutions
+		source *BuildSource
+		want   string
+	}{
+		{
+			"Uploaded by gcloud builds submit",
+			nil,
+			&BuildSource{StorageSource: &BuildStorageSource{Bucket: "nomos-sms_cloudbuild", Object: "source/1550106000.tgz"}},
+			"gs://nomos-sms_cloudbuild/source/1550106000.tgz",
+		},
+		{
+			"Cloud Source Repositories",
+			nil,
+			&BuildSource{RepoSource: &BuildRepoSource{RepoName: "nomos", TagName: "v1.0.0"}},
+			"nomos@v1.0.0",
+		},
+		{
+			"Git source",
+			nil,
+			&BuildSource{GitSource: &BuildGitSource{URL: "https://github.com/bm-sms/nomos.git", Revision: "main"}},
+			"https://github.com/bm-sms/nomos@main",
+		},
+		{
+			"Connected repository",
+			nil,
+			&BuildSource{ConnectedRepository: &BuildConnectedRepository{
+				Repository: "projects/nomos-sms/locations/asia-northeast1/connections/gh/repositories/nomos",
+				Revision:   "refs/heads/main",
+			}},
+			"nomos@main",
+		},
+		{
+			"Developer Connect",
+			nil,
+			&BuildSource{DeveloperConnect: &BuildDeveloperConnect{
+				GitRepositoryLink: "projects/nomos-sms/locations/asia-northeast1/connections/gl/gitRepositoryLinks/nomos",
+			}},
+			"nomos",
+		},
+		{
+			"Only substitutions",
+			&BuildSubstitutions{RepoName: "nomos", BranchName: "master"},
+			nil,
+			"nomos@master",
+		},
+		{"No sources", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				Source:        tt.source,
+				Substitutions: tt.sub,
+			}
+			if got := e.Origin(); got != tt.want {
+				t.Errorf("BuildEvent.Origin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBuildEvent_IsDeploy(t *testing.T) {
 	tests := []struct {
 		name string
@@ -165,7 +230,7 @@ func TestBuildEvent_IsDeploy(t *testing.T) {
 			e := BuildEvent{
 				Tags: tt.tags,
 			}
-			if got := e.IsDeploy(); got != tt.want {
+			if got := e.IsDeploy(&SlackConfig{}); got != tt.want {
 				t.Errorf("BuildEvent.IsDeploy() = %v, want %v", got, tt.want)
 			}
 		})
@@ -218,6 +283,13 @@ func TestBuildEvent_AppURLs(t *testing.T) {
 				{Title: "Admin URL", URL: "https://dev-dot-admin-dot-nomos-sms.appspot.com"},
 			},
 		},
+		{
+			"For deploying default service without branch",
+			&BuildTags{"deploy-default-service"},
+			"",
+			&SlackConfig{ProjectID: "nomos-sms"},
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -266,3 +338,96 @@ func TestRepositoryBranch_ToVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildSubstitutions_UnmarshalJSON(t *testing.T) {
+	data := `{
+		"BRANCH_NAME": "feature/slack",
+		"COMMIT_SHA": "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		"SHORT_SHA": "9f3b1e2",
+		"REPO_NAME": "nomos",
+		"REVISION_ID": "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		"TRIGGER_NAME": "pull-request",
+		"_PR_NUMBER": "128",
+		"_HEAD_BRANCH": "feature/slack",
+		"_BASE_BRANCH": "master",
+		"_DEPLOY_ENV": "staging"
+	}`
+	want := BuildSubstitutions{
+		BranchName:  "feature/slack",
+		CommitSHA:   "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		ShortSHA:    "9f3b1e2",
+		RepoName:    "nomos",
+		RevisionID:  "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		TriggerName: "pull-request",
+		PRNumber:    "128",
+		HeadBranch:  "feature/slack",
+		BaseBranch:  "master",
+		Custom:      map[string]string{"_DEPLOY_ENV": "staging"},
+	}
+
+	got := BuildSubstitutions{}
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("BuildSubstitutions = %v, want %v, differs: (-got +want;\n%s)", got, want, diff)
+	}
+}
+
+func TestBuildEvent_ShortSHA(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  *BuildSubstitutions
+		want string
+	}{
+		{"No substitutions", nil, ""},
+		{"SHORT_SHA", &BuildSubstitutions{CommitSHA: "9f3b1e2c4d5a", ShortSHA: "9f3b1e2"}, "9f3b1e2"},
+		{"Only COMMIT_SHA", &BuildSubstitutions{CommitSHA: "9f3b1e2c4d5a"}, "9f3b1e2"},
+		{"Only REVISION_ID", &BuildSubstitutions{RevisionID: "0a1b2c3d4e5f"}, "0a1b2c3"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				Substitutions: tt.sub,
+			}
+			if got := e.ShortSHA(); got != tt.want {
+				t.Errorf("BuildEvent.ShortSHA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEvent_FailedStep(t *testing.T) {
+	tests := []struct {
+		name         string
+		fixture      string
+		want         string
+		wantDuration time.Duration
+	}{
+		{"Failed step", "build_failure", "deploy-notify-slack (gcr.io/cloud-builders/gcloud)", 83400 * time.Millisecond},
+		{"Timed out step without end time", "build_timeout", "deploy-bakcup-firestore (gcr.io/cloud-builders/gcloud)", 9*time.Minute + 18*time.Second},
+		{"No failed steps", "build", "", 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := loadBuildEvent(t, tt.fixture)
+			s := e.FailedStep()
+			if tt.want == "" {
+				if s != nil {
+					t.Errorf("BuildEvent.FailedStep() = %v, want nil", s)
+				}
+				return
+			}
+			if s == nil || s.String() != tt.want {
+				t.Fatalf("BuildEvent.FailedStep() = %v, want %v", s, tt.want)
+			}
+			if got := e.StepDuration(s); got != tt.wantDuration {
+				t.Errorf("BuildEvent.StepDuration() = %v, want %v", got, tt.wantDuration)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package gcf
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/pkg/errors"
+)
+
+// MessagePublishedEvent is the type of CloudEvents which Pub/Sub delivers to 2nd gen functions.
+const MessagePublishedEvent = "google.cloud.pubsub.topic.v1.messagePublished"
+
+// MessagePublishedData is the data of MessagePublishedEvent.
+// See https://github.com/googleapis/google-cloudevents/blob/main/proto/google/events/cloud/pubsub/v1/data.proto
+type MessagePublishedData struct {
+	Message      PubSubMessage `json:"message"`
+	Subscription string        `json:"subscription"`
+}
+
+// NotifySlackCloudEvent is NotifySlack for 2nd gen functions with CloudEvents of the cloud-builds topic.
+func NotifySlackCloudEvent(ctx context.Context, e event.Event) error {
+	ctx, m, err := fromCloudEvent(ctx, e)
+	if err != nil {
+		return handleError(ctx, err)
+	}
+	return NotifySlack(ctx, m)
+}
+
+// BackupFirestoreCloudEvent is BackupFirestore for 2nd gen functions with CloudEvents of the backup-firestore topic.
+func BackupFirestoreCloudEvent(ctx context.Context, e event.Event) error {
+	ctx, m, err := fromCloudEvent(ctx, e)
+	if err != nil {
+		return handleError(ctx, err)
+	}
+	return BackupFirestore(ctx, m)
+}
+
+// fromCloudEvent returns the message of e and a context with the metadata which background functions get,
+// so that both kinds of functions share the same logic.
+// The traceparent extension of e is passed as the attribute of the message.
+func fromCloudEvent(ctx context.Context, e event.Event) (context.Context, PubSubMessage, error) {
+	if e.Type() != MessagePublishedEvent {
+		return ctx, PubSubMessage{}, Permanent(errors.Errorf("%s is not an event of Pub/Sub", e.Type()))
+	}
+
+	d := MessagePublishedData{}
+	if err := e.DataAs(&d); err != nil {
+		return ctx, PubSubMessage{}, errors.Wrap(Permanent(err), "Failed to decode the data of the event")
+	}
+
+	m := d.Message
+	if tp, ok := e.Extensions()["traceparent"].(string); ok && m.Attributes["traceparent"] == "" {
+		attrs := map[string]string{"traceparent": tp}
+		for k, v := range m.Attributes {
+			attrs[k] = v
+		}
+		m.Attributes = attrs
+	}
+
+	topic := strings.TrimPrefix(e.Source(), "//pubsub.googleapis.com/")
+	return pubsubContext(ctx, e.ID(), e.Time(), topic), m, nil
+}
+
+// pubsubContext returns a context with the metadata of a message published to the topic.
+func pubsubContext(ctx context.Context, id string, ts time.Time, topic string) context.Context {
+	return metadata.NewContext(ctx, &metadata.Metadata{
+		EventID:   id,
+		Timestamp: ts,
+		EventType: "google.pubsub.topic.publish",
+		Resource: &metadata.Resource{
+			Service: "pubsub.googleapis.com",
+			Name:    topic,
+			Type:    "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+		},
+	})
+}
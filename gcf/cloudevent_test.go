@@ -0,0 +1,83 @@
+package gcf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newMessagePublishedEvent(t *testing.T, typ string, data interface{}) event.Event {
+	t.Helper()
+	e := event.New()
+	e.SetID("1")
+	e.SetType(typ)
+	e.SetSource("//pubsub.googleapis.com/projects/nomos-sms/topics/cloud-builds")
+	e.SetTime(time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC))
+	if err := e.SetData(event.ApplicationJSON, data); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestFromCloudEvent(t *testing.T) {
+	d := MessagePublishedData{
+		Message: PubSubMessage{
+			Data:       "eyJpZCI6ImJ1aWxkLWlkIn0=",
+			Attributes: map[string]string{"buildId": "build-id"},
+			MessageID:  "123",
+		},
+		Subscription: "projects/nomos-sms/subscriptions/eventarc-notify-slack",
+	}
+	e := newMessagePublishedEvent(t, MessagePublishedEvent, d)
+	e.SetExtension("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, m, err := fromCloudEvent(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := PubSubMessage{
+		Data: "eyJpZCI6ImJ1aWxkLWlkIn0=",
+		Attributes: map[string]string{
+			"buildId":     "build-id",
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		MessageID: "123",
+	}
+	if diff := cmp.Diff(m, want); diff != "" {
+		t.Errorf("fromCloudEvent() message = %v, want %v, differs: (-got +want;\n%s)", m, want, diff)
+	}
+
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.EventID != "1" || !meta.Timestamp.Equal(e.Time()) ||
+		meta.Resource.Name != "projects/nomos-sms/topics/cloud-builds" {
+		t.Errorf("fromCloudEvent() metadata = %+v, want the event in projects/nomos-sms/topics/cloud-builds", meta)
+	}
+}
+
+func TestFromCloudEvent_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		event event.Event
+	}{
+		{"Not Pub/Sub", newMessagePublishedEvent(t, "google.cloud.storage.object.v1.finalized", MessagePublishedData{})},
+		{"Broken data", newMessagePublishedEvent(t, MessagePublishedEvent, "message")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := fromCloudEvent(context.Background(), tt.event)
+			if !IsPermanent(err) {
+				t.Errorf("fromCloudEvent() error = %v, want a permanent error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Command matchroute prints which route of the config file matches a build event.
+//
+//	$ matchroute -config config.yaml build.json
+//	$ gcloud builds describe $BUILD_ID --format json | matchroute -config config.yaml
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/bm-sms/nomos/gcf"
+)
+
+func main() {
+	config := flag.String("config", "config.yaml", "config file of NotifySlack")
+	flag.Parse()
+
+	err := run(*config, flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %+v", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func run(config, event string) error {
+	c, err := gcf.LoadFileConfig(config)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if event != "" && event != "-" {
+		f, err := os.Open(event)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	build := gcf.BuildEvent{}
+	if err := json.Unmarshal(d, &build); err != nil {
+		return err
+	}
+
+	fmt.Printf("Build: %s (project: %s, branch: %s, status: %s, trigger: %s)\n",
+		build.ID, build.ProjectID, build.Branch(), build.Status, build.BuildTriggerID)
+
+	// Projects in the config file have their own routes like NotifySlack.
+	// The build is regarded as one of GCP_PROJECT unless it is set.
+	home := os.Getenv("GCP_PROJECT")
+	if home == "" {
+		home = build.ProjectID
+	}
+	pc, ok := (&gcf.SlackConfig{ProjectID: home, File: *c}).ForProject(build.ProjectID)
+	if !ok {
+		fmt.Printf("Project %s is not watched, so it isn't notified\n", build.ProjectID)
+		return nil
+	}
+	if route := pc.File.MatchRoute(build); route != nil {
+		fmt.Printf("Matched route: %s\n", route)
+	} else {
+		fmt.Println("No routes matched, so it goes to the default destination")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Command notifyslack replays a build event through NotifySlack locally.
+// It is configured with the same environment variables as the function.
+//
+//	$ notifyslack -dry-run build.json
+//	$ gcloud builds describe $BUILD_ID --format json | notifyslack -dry-run
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/bm-sms/nomos/gcf"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print the message of Slack instead of sending it")
+	eventID := flag.String("event-id", fmt.Sprintf("replay-%d", time.Now().UnixNano()), "ID of the event")
+	flag.Parse()
+
+	// Logs go to stderr not to be mixed with the message.
+	gcf.SetDefaultLogger(gcf.NewLogger(os.Stderr))
+
+	err := run(*dryRun, *eventID, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func run(dryRun bool, eventID, event string) error {
+	var r io.Reader = os.Stdin
+	if event != "" && event != "-" {
+		f, err := os.Open(event)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	build := gcf.BuildEvent{}
+	if err := json.Unmarshal(d, &build); err != nil {
+		return err
+	}
+
+	// The build is regarded as one of the watched project.
+	if os.Getenv("GCP_PROJECT") == "" {
+		if err := os.Setenv("GCP_PROJECT", build.ProjectID); err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		if err := setupDryRun(); err != nil {
+			return err
+		}
+	}
+
+	// Cloud Build publishes the build as the data of the message with its ID and status.
+	m := gcf.PubSubMessage{
+		Data:       base64.StdEncoding.EncodeToString(d),
+		Attributes: map[string]string{"buildId": build.ID, "status": build.Status},
+	}
+	ctx := metadata.NewContext(context.Background(), &metadata.Metadata{
+		EventID:   eventID,
+		Timestamp: time.Now(),
+		EventType: "google.pubsub.topic.publish",
+		Resource: &metadata.Resource{
+			Service: "pubsub.googleapis.com",
+			Name:    fmt.Sprintf("projects/%s/topics/cloud-builds", os.Getenv("GCP_PROJECT")),
+			Type:    "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+		},
+	})
+	// Permanent failures are returned unlike NotifySlack, so that the command fails with them.
+	reason, err := gcf.ReplayNotifySlack(ctx, m)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		fmt.Fprintf(os.Stderr, "Not notified: %s\n", reason)
+	}
+	return nil
+}
+
+// setupDryRun replaces the Slack notifier with one which prints the message,
+// keeps stores in memory so that nothing is written to Firestore, and doesn't read logs from Cloud Storage.
+func setupDryRun() error {
+	for _, k := range []string{"DEDUP_STORE", "MESSAGE_STORE", "HISTORY_STORE"} {
+		if os.Getenv(k) == "firestore" {
+			if err := os.Setenv(k, "memory"); err != nil {
+				return err
+			}
+		}
+	}
+	if s := os.Getenv("LOG_STORAGE"); s == "" || s == "gcs" {
+		if err := os.Setenv("LOG_STORAGE", "none"); err != nil {
+			return err
+		}
+	}
+
+	gcf.RegisterNotifier("slack", gcf.NotifierFunc(func(ctx context.Context, b gcf.BuildEvent, c *gcf.SlackConfig) error {
+		msg := gcf.RenderSlackMessage(b, c)
+		// Webhooks post to their own channels.
+		if c.UpdatesMessage() {
+			msg.Channel = c.SlackChannel
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(msg)
+	}))
+	return nil
+}
@@ -0,0 +1,41 @@
+// Command pushserver serves NotifySlack for a push subscription of Pub/Sub such as on Cloud Run.
+// It is configured with the same environment variables as the function and PUSH_* ones.
+// With SLACK_SIGNING_SECRET, it also serves interactions of Slack at /slack/interactions.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bm-sms/nomos/gcf"
+)
+
+func main() {
+	h, err := gcf.NewNotifySlackHandler()
+	if err != nil {
+		fmt.Printf("Error: %+v", err)
+		os.Exit(1)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+
+	if os.Getenv("SLACK_SIGNING_SECRET") != "" {
+		ih, err := gcf.NewSlackInteractionHandler(context.Background())
+		if err != nil {
+			fmt.Printf("Error: %+v", err)
+			os.Exit(1)
+		}
+		mux.Handle("/slack/interactions", ih)
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
+		fmt.Printf("Error: %+v", err)
+		os.Exit(1)
+	}
+}
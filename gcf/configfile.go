@@ -0,0 +1,100 @@
+package gcf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// FileConfig has settings of NotifySlack which are too complex for environment variables.
+// It is read from the YAML file at CONFIG_FILE.
+type FileConfig struct {
+	NotifyStatuses []string               `yaml:"notifyStatuses"`
+	Statuses       map[string]SlackStatus `yaml:"statuses"`
+	Routes         []Route                `yaml:"routes"`
+	// ChannelLanguages selects the language of messages posted to each channel.
+	ChannelLanguages map[string]string `yaml:"channelLanguages"`
+	// Deploys has URLs of apps deployed by builds with each tag.
+	Deploys map[string][]*DeployURL `yaml:"deploys"`
+	// Repositories are looked up by the name of a connected repository, REPO_NAME or
+	// a repository of Cloud Source Repositories.
+	Repositories map[string]Repository `yaml:"repositories"`
+	// Messages has templates of messages for each status or "default" for the others.
+	Messages map[string]*MessageTemplate `yaml:"messages"`
+	// Projects are watched in addition to GCP_PROJECT with their own settings.
+	Projects []ProjectConfig `yaml:"projects"`
+	// Redact has regular expressions of secrets to be redacted from logs in addition to the defaults.
+	Redact []string `yaml:"redact"`
+
+	redactPatterns []*regexp.Regexp
+}
+
+// LoadFileConfig reads and validates the config file.
+func LoadFileConfig(path string) (*FileConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the config file")
+	}
+
+	c := &FileConfig{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, errors.Wrapf(err, "Failed to decode %s", path)
+	}
+
+	for i := range c.Routes {
+		if c.Routes[i].Name == "" {
+			c.Routes[i].Name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := c.Routes[i].validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid %s", path)
+		}
+	}
+	ids := map[string]bool{}
+	for i := range c.Projects {
+		if err := c.Projects[i].validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid %s", path)
+		}
+		if ids[c.Projects[i].ID] {
+			return nil, errors.Errorf("project %s is duplicated in %s", c.Projects[i].ID, path)
+		}
+		ids[c.Projects[i].ID] = true
+	}
+	for ch, lang := range c.ChannelLanguages {
+		if !validLanguage(lang) {
+			return nil, errors.Errorf("%s of channel %s is unknown language in %s", lang, ch, path)
+		}
+	}
+	for tag, us := range c.Deploys {
+		for _, u := range us {
+			if err := u.compile(tag); err != nil {
+				return nil, errors.Wrapf(err, "Invalid %s", path)
+			}
+		}
+	}
+	for name, r := range c.Repositories {
+		switch r.Host {
+		case "", GitHub, GitLab, Bitbucket, CloudSourceRepositories:
+		default:
+			return nil, errors.Errorf("%s of repository %s is unknown host in %s", r.Host, name, path)
+		}
+	}
+	for status, m := range c.Messages {
+		if _, ok := statusMap[status]; !ok && status != DefaultMessageTemplate {
+			return nil, errors.Errorf("%s of messages is unknown status in %s", status, path)
+		}
+		if err := m.compile(status); err != nil {
+			return nil, errors.Wrapf(err, "Invalid %s", path)
+		}
+	}
+	for _, r := range c.Redact {
+		p, err := regexp.Compile(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid redact pattern in %s", path)
+		}
+		c.redactPatterns = append(c.redactPatterns, p)
+	}
+	return c, nil
+}
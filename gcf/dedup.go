@@ -0,0 +1,188 @@
+package gcf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+)
+
+// DedupStore remembers keys of handled events for a while,
+// because Cloud Functions delivers an event at least once.
+type DedupStore interface {
+	// Claim records key and reports true unless it has been recorded within ttl.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release forgets key to handle the event again on a retry.
+	Release(ctx context.Context, key string) error
+}
+
+var (
+	dedupStore     DedupStore
+	onceDedupStore try.Once
+)
+
+// getDedupStore returns nil if deduplication is disabled.
+func getDedupStore(ctx context.Context, c *SlackConfig) (DedupStore, error) {
+	err := onceDedupStore.Try(func() error {
+		var err error
+		dedupStore, err = newDedupStore(ctx, c)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return dedupStore, nil
+}
+
+func newDedupStore(ctx context.Context, c *SlackConfig) (DedupStore, error) {
+	switch c.DedupStore {
+	case "none":
+		return nil, nil
+	case "memory":
+		return NewMemoryDedupStore(), nil
+	case "firestore":
+		return NewFirestoreDedupStore(ctx, c.homeProject(), c.DedupCollection)
+	}
+	return nil, errors.Errorf("%s is unknown dedup store", c.DedupStore)
+}
+
+// notifyOnce notifies b unless the event or the status of the build has been notified.
+// Keys are released if it fails so that a retry notifies again.
+func notifyOnce(ctx context.Context, s DedupStore, eventID string, b BuildEvent, c *SlackConfig) error {
+	if s == nil {
+		return notify(ctx, b, c)
+	}
+
+	keys := []string{eventKey(eventID), buildStatusKey(b)}
+	ok, err := claimAll(ctx, s, c.DedupTTL, keys...)
+	if err != nil {
+		return errors.Wrap(err, "Failed to deduplicate the event")
+	}
+	if !ok {
+		logger(ctx).Infof("%s of %s has been notified", b.Status, b.ID)
+		return nil
+	}
+
+	if err := notify(ctx, b, c); err != nil {
+		if rerr := releaseAll(ctx, s, keys...); rerr != nil {
+			logger(ctx).Error(rerr, "Failed to release claimed keys")
+		}
+		return err
+	}
+	return nil
+}
+
+func eventKey(eventID string) string {
+	return fmt.Sprintf("event-%s", eventID)
+}
+
+func buildStatusKey(b BuildEvent) string {
+	return fmt.Sprintf("build-%s-%s", b.ID, b.Status)
+}
+
+// claimAll claims keys in order. If one of them is already claimed,
+// the keys claimed so far are released and it reports false.
+func claimAll(ctx context.Context, s DedupStore, ttl time.Duration, keys ...string) (bool, error) {
+	for i, k := range keys {
+		ok, err := s.Claim(ctx, k, ttl)
+		if err == nil && ok {
+			continue
+		}
+		if rerr := releaseAll(ctx, s, keys[:i]...); rerr != nil {
+			logger(ctx).Error(rerr, "Failed to release claimed keys")
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func releaseAll(ctx context.Context, s DedupStore, keys ...string) error {
+	var failed error
+	for _, k := range keys {
+		if err := s.Release(ctx, k); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// MemoryDedupStore remembers keys while the instance of the function is alive.
+type MemoryDedupStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{expires: map[string]time.Time{}, now: time.Now}
+}
+
+func (s *MemoryDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, exp := range s.expires {
+		if !now.Before(exp) {
+			delete(s.expires, k)
+		}
+	}
+	if _, ok := s.expires[key]; ok {
+		return false, nil
+	}
+	s.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, key)
+	return nil
+}
+
+// FirestoreDedupStore remembers keys as documents of a Firestore collection,
+// so that every instance of the function shares them.
+type FirestoreDedupStore struct {
+	col *firestoreCollection
+	now func() time.Time
+}
+
+func NewFirestoreDedupStore(ctx context.Context, projectID, collection string) (*FirestoreDedupStore, error) {
+	col, err := newFirestoreCollection(ctx, projectID, collection)
+	if err != nil {
+		return nil, err
+	}
+	return &FirestoreDedupStore{col: col, now: time.Now}, nil
+}
+
+func (s *FirestoreDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	fields := map[string]string{"expireAt": s.now().Add(ttl).Format(time.RFC3339Nano)}
+	created, err := s.col.create(ctx, key, fields)
+	if err != nil || created {
+		return created, err
+	}
+
+	old, updateTime, err := s.col.getWithUpdateTime(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if old == nil {
+		// It was released just now.
+		return s.col.create(ctx, key, fields)
+	}
+	exp, err := time.Parse(time.RFC3339Nano, old["expireAt"])
+	if err == nil && s.now().Before(exp) {
+		return false, nil
+	}
+	// The key is expired, so take it over unless another instance does first.
+	return s.col.putIf(ctx, key, updateTime, fields)
+}
+
+func (s *FirestoreDedupStore) Release(ctx context.Context, key string) error {
+	return s.col.delete(ctx, key)
+}
@@ -0,0 +1,80 @@
+package gcf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	s := NewMemoryDedupStore()
+	s.now = func() time.Time { return now }
+
+	claim := func(key string, want bool) {
+		t.Helper()
+		got, err := s.Claim(ctx, key, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("MemoryDedupStore.Claim(%s) at %s = %v, want %v", key, now, got, want)
+		}
+	}
+
+	claim("a", true)
+	claim("a", false)
+	claim("b", true)
+
+	now = now.Add(time.Hour)
+	claim("a", true)
+
+	if err := s.Release(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	claim("a", true)
+}
+
+func TestNotifyOnce(t *testing.T) {
+	var count int
+	var fail bool
+	RegisterNotifier("test-once", NotifierFunc(func(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+		count++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	ctx := context.Background()
+	s := NewMemoryDedupStore()
+	c := &SlackConfig{Notifiers: []string{"test-once"}, DedupTTL: time.Hour}
+	success := BuildEvent{ID: "build-id", Status: "SUCCESS"}
+
+	steps := []struct {
+		name      string
+		eventID   string
+		build     BuildEvent
+		fail      bool
+		wantCount int
+		wantErr   bool
+	}{
+		{"First delivery", "1", success, false, 1, false},
+		{"Redelivery of the same event", "1", success, false, 1, false},
+		{"Another event of the same status", "2", success, false, 1, false},
+		{"Failure is notified", "3", BuildEvent{ID: "build-id", Status: "FAILURE"}, true, 2, true},
+		{"Retry of the failed event", "3", BuildEvent{ID: "build-id", Status: "FAILURE"}, false, 3, false},
+	}
+	for _, st := range steps {
+		fail = st.fail
+		err := notifyOnce(ctx, s, st.eventID, st.build, c)
+		if (err != nil) != st.wantErr {
+			t.Errorf("%s: notifyOnce() error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+		if count != st.wantCount {
+			t.Errorf("%s: notified %d times, want %d", st.name, count, st.wantCount)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package gcf
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDeployURLTemplate is the URL of the App Engine version named after the branch.
+// The master branch is served as the default version, and builds without branches have no URLs.
+const DefaultDeployURLTemplate = `{{if .Branch}}https://{{if ne .Branch "master"}}{{.Version}}-dot-{{end}}` +
+	`{{with .Service}}{{.}}-dot-{{end}}{{.Domain}}{{end}}`
+
+// DeployURL is a URL of an app deployed by a build with a tag.
+type DeployURL struct {
+	Title string `yaml:"title"`
+	// Service is a service of App Engine. It is empty for the default service.
+	Service string `yaml:"service"`
+	// URL is a text/template with DeployURLParams. It is DefaultDeployURLTemplate if empty.
+	// URLs rendered as blank are omitted.
+	URL string `yaml:"url"`
+
+	tmpl *template.Template
+}
+
+// DeployURLParams can be used in templates of DeployURL.
+type DeployURLParams struct {
+	Branch  RepositoryBranch
+	Version string
+	Project string
+	Service string
+	// Domain is <project>.appspot.com unless the project has another one in the config file.
+	Domain string
+}
+
+// defaultDeploys are used unless deploys are given in the config file.
+var defaultDeploys = map[string][]*DeployURL{
+	TagDeployDefault: {
+		{Title: "SMS URL"},
+		{Title: "SMS Career URL", Service: "smsc"},
+	},
+	TagDeployAdmin: {
+		{Title: "Admin URL", Service: "admin"},
+	},
+}
+
+func init() {
+	for tag, us := range defaultDeploys {
+		for _, u := range us {
+			if err := u.compile(tag); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// compile parses the template and tries it once to find mistakes at cold start.
+func (u *DeployURL) compile(tag string) error {
+	text := u.URL
+	if text == "" {
+		text = DefaultDeployURLTemplate
+	}
+
+	tmpl, err := template.New(tag).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to parse URL of %s for %s", u.Title, tag)
+	}
+	u.tmpl = tmpl
+
+	_, err = u.render(DeployURLParams{
+		Branch: "master", Version: "master", Project: "project", Service: u.Service, Domain: "project.appspot.com",
+	})
+	return errors.Wrapf(err, "Failed to render URL of %s for %s", u.Title, tag)
+}
+
+func (u *DeployURL) render(p DeployURLParams) (string, error) {
+	var sb strings.Builder
+	if err := u.tmpl.Execute(&sb, p); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return sb.String(), nil
+}
+
+func (c *SlackConfig) deploys() map[string][]*DeployURL {
+	if c.File.Deploys != nil {
+		return c.File.Deploys
+	}
+	return defaultDeploys
+}
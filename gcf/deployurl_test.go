@@ -0,0 +1,90 @@
+package gcf
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildEvent_AppURLs_withConfigFile(t *testing.T) {
+	f, err := LoadFileConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &SlackConfig{ProjectID: "nomos-sms", File: *f}
+
+	tests := []struct {
+		name   string
+		tags   *BuildTags
+		branch string
+		want   []AppURL
+	}{
+		{
+			"For deploying api service with master branch",
+			&BuildTags{"deploy-api-service"},
+			"master",
+			[]AppURL{
+				{Title: "API URL", URL: "https://api-dot-nomos-sms.appspot.com"},
+				{Title: "API Docs", URL: "https://master-dot-api-dot-nomos-sms.appspot.com/docs"},
+			},
+		},
+		{
+			"For deploying api service with feature branch",
+			&BuildTags{"test", "deploy-api-service"},
+			"feature/docs",
+			[]AppURL{
+				{Title: "API URL", URL: "https://feature-docs-dot-api-dot-nomos-sms.appspot.com"},
+				{Title: "API Docs", URL: "https://feature-docs-dot-api-dot-nomos-sms.appspot.com/docs"},
+			},
+		},
+		{
+			"For deploying api service without branch",
+			&BuildTags{"deploy-api-service"},
+			"",
+			nil,
+		},
+		{
+			"Default deploys are replaced by the config file",
+			&BuildTags{"deploy-default-service"},
+			"master",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				Source: &BuildSource{RepoSource: &BuildRepoSource{BranchName: tt.branch}},
+				Tags:   tt.tags,
+			}
+			got := e.AppURLs(c)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("BuildEvent.AppURLs() = %v, want %v, differs: (-got +want;\n%s)", got, tt.want, diff)
+			}
+		})
+	}
+}
+
+func TestDeployURL_compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"Default template", "", false},
+		{"Custom template", "https://{{.Version}}.example.com/{{.Branch}}", false},
+		{"Broken template", "https://{{.Version", true},
+		{"Unknown field", "https://{{.Tag}}.example.com", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			u := &DeployURL{Title: "URL", URL: tt.url}
+			if err := u.compile("deploy"); (err != nil) != tt.wantErr {
+				t.Errorf("DeployURL.compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
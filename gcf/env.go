@@ -1,7 +1,9 @@
 package gcf
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
@@ -9,8 +11,55 @@ import (
 )
 
 type SlackConfig struct {
-	ProjectID    string `envconfig:"gcp_project"`
-	SlackWebhook string `envconfig:"slack_webhook"`
+	ProjectID    string   `envconfig:"gcp_project"`
+	SlackWebhook string   `envconfig:"slack_webhook"`
+	Notifiers    []string `envconfig:"notifiers" default:"slack"`
+	// SlackBotToken enables to update a single message through the lifecycle of a build
+	// instead of posting to SlackWebhook.
+	SlackBotToken string `envconfig:"slack_bot_token"`
+	SlackChannel  string `envconfig:"slack_channel"`
+	// SlackSigningSecret verifies requests of interactive buttons, which are shown only with it.
+	SlackSigningSecret     string `envconfig:"slack_signing_secret"`
+	MessageStore           string `envconfig:"message_store" default:"memory"`
+	MessageStorePath       string `envconfig:"message_store_path" default:"/tmp/slack-messages.json"`
+	MessageStoreCollection string `envconfig:"message_store_collection" default:"slack-messages"`
+	// NotifyStatuses takes precedence over notifyStatuses in ConfigFile.
+	NotifyStatuses []string `envconfig:"notify_statuses"`
+	RepositoryURL  string   `envconfig:"repository_url"`
+	// DedupStore is "memory", "firestore" or "none" to notify each status of a build once.
+	DedupStore      string        `envconfig:"dedup_store" default:"memory"`
+	DedupTTL        time.Duration `envconfig:"dedup_ttl" default:"24h"`
+	DedupCollection string        `envconfig:"dedup_collection" default:"notify-slack-events"`
+	// HistoryStore is "memory", "firestore" or "none" to keep the last result of each branch
+	// for failure streaks and recoveries.
+	HistoryStore      string `envconfig:"history_store" default:"none"`
+	HistoryCollection string `envconfig:"history_collection" default:"notify-slack-branches"`
+	// LogLines is how many lines of the log of the failed step are shown on failure and timeout.
+	// LogStorage is "gcs", "dir" or "none", and "dir" reads LogDir/<bucket>/log-<id>.txt.
+	LogLines   int    `envconfig:"log_lines" default:"20"`
+	LogStorage string `envconfig:"log_storage" default:"gcs"`
+	LogDir     string `envconfig:"log_dir"`
+	// StepTimeline shows when each step ran on failure and timeout.
+	StepTimeline bool `envconfig:"step_timeline"`
+	// Language is "en" or "ja" of messages, which routes and channels in ConfigFile can override.
+	// TimeZone is a name of the IANA Time Zone database such as "Asia/Tokyo" to show times of builds.
+	Language string `envconfig:"language" default:"en"`
+	TimeZone string `envconfig:"time_zone" default:"UTC"`
+	// SecretProvider is "secretmanager", "env" or "file" to resolve settings like SLACK_WEBHOOK=secret://<name>.
+	// "file" reads SecretDir/<name>, and secrets are fetched again after SecretTTL.
+	SecretProvider string        `envconfig:"secret_provider" default:"secretmanager"`
+	SecretDir      string        `envconfig:"secret_dir" default:"/etc/secrets"`
+	SecretTTL      time.Duration `envconfig:"secret_ttl" default:"10m"`
+	ConfigFile     string        `envconfig:"config_file"`
+	File           FileConfig    `ignored:"true"`
+
+	location *time.Location
+	// domain is the one of a project in ConfigFile.
+	domain string
+	// homeProjectID is GCP_PROJECT of configs for other projects.
+	homeProjectID string
+	// secretRefs has names of secrets resolved by withSecrets, keyed by their settings.
+	secretRefs map[string]string
 }
 
 type FirestoreConfig struct {
@@ -24,33 +73,156 @@ var (
 	onceFirestoreConfig try.Once
 )
 
-func getSlackConfig() (*SlackConfig, error) {
+// getSlackConfig returns the config whose references to secrets are resolved.
+// Secrets are cached for SECRET_TTL, so that rotated ones are used without redeploys.
+// Only failures of the settings are permanent, and failures to get secrets are classified by the provider.
+func getSlackConfig(ctx context.Context) (*SlackConfig, error) {
 	err := onceSlackConfig.Try(func() error {
-		return envconfig.Process("", &slackConfig)
+		if err := envconfig.Process("", &slackConfig); err != nil {
+			return err
+		}
+		return slackConfig.load()
 	})
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, Permanent(errors.WithStack(err))
 	}
 
-	return &slackConfig, nil
+	return slackConfig.withSecrets(ctx)
 }
 
-func (c *SlackConfig) DefaultDomain() string {
-	return fmt.Sprintf("%s.appspot.com", c.ProjectID)
+// load reads ConfigFile and validates the whole settings.
+func (c *SlackConfig) load() error {
+	if c.ConfigFile != "" {
+		f, err := LoadFileConfig(c.ConfigFile)
+		if err != nil {
+			return err
+		}
+		c.File = *f
+	}
+
+	for _, s := range c.notifyStatuses() {
+		if _, ok := statusMap[s]; !ok {
+			return errors.Errorf("%s is unknown status to notify", s)
+		}
+	}
+	for s := range c.File.Statuses {
+		if _, ok := statusMap[s]; !ok {
+			return errors.Errorf("%s is unknown status in %s", s, c.ConfigFile)
+		}
+	}
+	routes := c.File.Routes
+	for _, p := range c.File.Projects {
+		routes = append(routes[:len(routes):len(routes)], p.Routes...)
+	}
+	for _, r := range routes {
+		if r.OnlyStateChanges && c.HistoryStore == "none" {
+			return errors.Errorf("route %s notifies only state changes, but HISTORY_STORE is none", r.Name)
+		}
+		if err := c.validateDestination("route "+r.Name, r.Channel, r.Webhook); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.File.Projects {
+		if err := c.validateDestination("project "+p.ID, p.Channel, p.Webhook); err != nil {
+			return err
+		}
+	}
+	if err := c.validateDestination("SLACK_CHANNEL", c.SlackChannel, ""); err != nil {
+		return err
+	}
+	if c.UpdatesMessage() && c.SlackChannel == "" {
+		return errors.New("SLACK_BOT_TOKEN is set, but SLACK_CHANNEL is not set for builds without channels of routes")
+	}
+	if _, ok := ownerURL(c.repositoryURL()); !ok {
+		return errors.Errorf("%s is not a URL of a repository such as https://github.com/<owner>/<repo>", c.RepositoryURL)
+	}
+	if c.Language != "" && !validLanguage(c.Language) {
+		return errors.Errorf("%s is unknown language", c.Language)
+	}
+	switch c.SecretProvider {
+	case "", "secretmanager", "env", "file":
+	default:
+		return errors.Errorf("%s is unknown secret provider", c.SecretProvider)
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load time zone %s", c.TimeZone)
+	}
+	c.location = loc
+
+	return nil
 }
 
-func (c *SlackConfig) CareerDomain() string {
-	return fmt.Sprintf("smsc-dot-%s", c.DefaultDomain())
+// validateDestination rejects destinations which would be ignored,
+// because messages are posted to channels only with SLACK_BOT_TOKEN, and to webhooks only without it.
+func (c *SlackConfig) validateDestination(name, channel, webhook string) error {
+	if channel != "" && !c.UpdatesMessage() {
+		return errors.Errorf("%s has a channel, but SLACK_BOT_TOKEN is not set to post to it", name)
+	}
+	if webhook != "" && c.UpdatesMessage() {
+		return errors.Errorf("%s has a webhook, but SLACK_BOT_TOKEN posts to channels instead", name)
+	}
+	return nil
 }
 
-func (c *SlackConfig) AdminDomain() string {
-	return fmt.Sprintf("admin-dot-%s", c.DefaultDomain())
+func (c *SlackConfig) notifyStatuses() []string {
+	if len(c.NotifyStatuses) > 0 {
+		return c.NotifyStatuses
+	}
+	if len(c.File.NotifyStatuses) > 0 {
+		return c.File.NotifyStatuses
+	}
+	return DefaultNotifyStatuses
+}
+
+// Notifies reports whether a build in the status is notified.
+func (c *SlackConfig) Notifies(status string) bool {
+	return includedStatus(c.notifyStatuses(), status)
+}
+
+// SlackStatus returns how the status looks, which the config file can override.
+func (c *SlackConfig) SlackStatus(status string) SlackStatus {
+	s := statusMap[status]
+	if o, ok := c.File.Statuses[status]; ok {
+		if o.Color != "" {
+			s.Color = o.Color
+		}
+		if o.Icon != "" {
+			s.Icon = o.Icon
+		}
+	}
+	return s
+}
+
+func (c *SlackConfig) repositoryURL() string {
+	if c.RepositoryURL != "" {
+		return c.RepositoryURL
+	}
+	return RepositoryURL
+}
+
+// DefaultDomain is the domain of App Engine of the project unless the config file gives another one.
+func (c *SlackConfig) DefaultDomain() string {
+	if c.domain != "" {
+		return c.domain
+	}
+	return fmt.Sprintf("%s.appspot.com", c.ProjectID)
 }
 
 func (c *SlackConfig) SlackWebhookURL() string {
 	return fmt.Sprintf("https://hooks.slack.com/services/%s", c.SlackWebhook)
 }
 
+// UpdatesMessage reports whether a message is updated on each status of a build.
+func (c *SlackConfig) UpdatesMessage() bool {
+	return c.SlackBotToken != ""
+}
+
+// Interactive reports whether messages have buttons to retry and cancel builds.
+func (c *SlackConfig) Interactive() bool {
+	return c.SlackSigningSecret != ""
+}
+
 func (c *SlackConfig) WatchingResource() string {
 	return fmt.Sprintf("projects/%s/topics/cloud-builds", c.ProjectID)
 }
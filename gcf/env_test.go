@@ -16,18 +16,6 @@ func TestSlackConfig(t *testing.T) {
 		t.Errorf("SlackConfig.DefaultDomain() returns %s, but want %s", got, want)
 	}
 
-	got = config.CareerDomain()
-	want = "smsc-dot-test-project.appspot.com"
-	if got != want {
-		t.Errorf("SlackConfig.CareerDomain() returns %s, but want %s", got, want)
-	}
-
-	got = config.AdminDomain()
-	want = "admin-dot-test-project.appspot.com"
-	if got != want {
-		t.Errorf("SlackConfig.AdminDomain() returns %s, but want %s", got, want)
-	}
-
 	got = config.SlackWebhookURL()
 	want = "https://hooks.slack.com/services/hook"
 	if got != want {
@@ -52,3 +40,107 @@ func TestFirestoreConfig(t *testing.T) {
 		t.Errorf("FirestoreConfig.DatabaseName() returns %s, but want %s", got, want)
 	}
 }
+
+func TestSlackConfig_load(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *SlackConfig
+		wantErr bool
+	}{
+		{"Without config file", &SlackConfig{}, false},
+		{"With config file", &SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", ConfigFile: "testdata/config.yaml"}, false},
+		{"Channels without bot token", &SlackConfig{ConfigFile: "testdata/config.yaml"}, true},
+		{"Channel without bot token", &SlackConfig{SlackChannel: "#ci"}, true},
+		{"Bot token without channel", &SlackConfig{SlackBotToken: "xoxb-test"}, true},
+		{
+			"Webhook of route with bot token",
+			&SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", File: FileConfig{Routes: []Route{{Name: "nightly", Webhook: "T0/B0/X"}}}},
+			true,
+		},
+		{
+			"Webhook of project with bot token",
+			&SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", File: FileConfig{Projects: []ProjectConfig{{ID: "nomos-staging", Webhook: "T0/B0/X"}}}},
+			true,
+		},
+		{"Webhook of route", &SlackConfig{File: FileConfig{Routes: []Route{{Name: "nightly", Webhook: "T0/B0/X"}}}}, false},
+		{"Missing config file", &SlackConfig{ConfigFile: "testdata/missing.yaml"}, true},
+		{"Unknown status", &SlackConfig{NotifyStatuses: []string{"SUCCESS", "FAILED"}}, true},
+		{"Japanese in Tokyo", &SlackConfig{Language: "ja", TimeZone: "Asia/Tokyo"}, false},
+		{"Unknown language", &SlackConfig{Language: "jp"}, true},
+		{"Repository of GitLab", &SlackConfig{RepositoryURL: "https://gitlab.com/bm-sms/group/nomos"}, false},
+		{"Repository without owner", &SlackConfig{RepositoryURL: "https://github.com"}, true},
+		{"Repository without host", &SlackConfig{RepositoryURL: "nomos"}, true},
+		{"Unknown time zone", &SlackConfig{TimeZone: "Asia/Osaka"}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.config.load(); (err != nil) != tt.wantErr {
+				t.Errorf("SlackConfig.load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSlackConfig_Notifies(t *testing.T) {
+	fromFile := &SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", ConfigFile: "testdata/config.yaml"}
+	if err := fromFile.load(); err != nil {
+		t.Fatal(err)
+	}
+	fromEnv := &SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", ConfigFile: "testdata/config.yaml", NotifyStatuses: []string{"EXPIRED"}}
+	if err := fromEnv.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config *SlackConfig
+		status string
+		want   bool
+	}{
+		{"Default statuses", &SlackConfig{}, "TIMEOUT", true},
+		{"Not in default statuses", &SlackConfig{}, "CANCELLED", false},
+		{"Statuses in config file", fromFile, "CANCELLED", true},
+		{"Not in config file", fromFile, "TIMEOUT", false},
+		{"Environment variable takes precedence", fromEnv, "EXPIRED", true},
+		{"Environment variable overrides config file", fromEnv, "SUCCESS", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.config.Notifies(tt.status); got != tt.want {
+				t.Errorf("SlackConfig.Notifies(%s) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackConfig_SlackStatus(t *testing.T) {
+	config := &SlackConfig{SlackBotToken: "xoxb-test", SlackChannel: "#ci", ConfigFile: "testdata/config.yaml"}
+	if err := config.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status string
+		want   SlackStatus
+	}{
+		{"SUCCESS", SlackStatus{Color: "#2aa24b", Icon: ":white_check_mark:"}},
+		{"FAILURE", SlackStatus{Color: "#ff0000", Icon: ":x:"}},
+		{"INTERNAL_ERROR", SlackStatus{Color: "#d50200", Icon: ":sos:"}},
+		{"TIMEOUT", SlackStatus{Color: "#de9d2e", Icon: ":sos:"}},
+		{"CANCELLED", SlackStatus{Color: "#9e9e9e", Icon: ":wastebasket:"}},
+		{"PENDING", SlackStatus{Color: "#de9d2e", Icon: ":raised_hand:"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.status, func(t *testing.T) {
+			t.Parallel()
+			if got := config.SlackStatus(tt.status); got != tt.want {
+				t.Errorf("SlackConfig.SlackStatus(%s) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package gcf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// firestoreCollection reads and writes documents which have only string fields
+// through the REST API of Firestore.
+type firestoreCollection struct {
+	docs     *firestore.ProjectsDatabasesDocumentsService
+	database string
+	name     string
+}
+
+func newFirestoreCollection(ctx context.Context, projectID, name string) (*firestoreCollection, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/datastore")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a Google client")
+	}
+
+	svc, err := firestore.New(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create Firestore service")
+	}
+
+	c := &FirestoreConfig{ProjectID: projectID}
+	return &firestoreCollection{
+		docs:     firestore.NewProjectsDatabasesDocumentsService(svc),
+		database: c.DatabaseName(),
+		name:     name,
+	}, nil
+}
+
+func (c *firestoreCollection) docName(id string) string {
+	return fmt.Sprintf("%s/documents/%s/%s", c.database, c.name, id)
+}
+
+func hasStatus(err error, code int) bool {
+	e, ok := err.(*googleapi.Error)
+	return ok && e.Code == code
+}
+
+func toDocument(fields map[string]string) *firestore.Document {
+	doc := &firestore.Document{Fields: make(map[string]firestore.Value, len(fields))}
+	for k, v := range fields {
+		doc.Fields[k] = firestore.Value{StringValue: v}
+	}
+	return doc
+}
+
+// get returns nil without any errors if the document doesn't exist.
+func (c *firestoreCollection) get(ctx context.Context, id string) (map[string]string, error) {
+	fields, _, err := c.getWithUpdateTime(ctx, id)
+	return fields, err
+}
+
+// getWithUpdateTime also returns when the document was updated to write it with a precondition.
+func (c *firestoreCollection) getWithUpdateTime(ctx context.Context, id string) (map[string]string, string, error) {
+	doc, err := c.docs.Get(c.docName(id)).Context(ctx).Do()
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to get %s from Firestore", id)
+	}
+
+	fields := make(map[string]string, len(doc.Fields))
+	for k, v := range doc.Fields {
+		fields[k] = v.StringValue
+	}
+	return fields, doc.UpdateTime, nil
+}
+
+func (c *firestoreCollection) put(ctx context.Context, id string, fields map[string]string) error {
+	_, err := c.docs.Patch(c.docName(id), toDocument(fields)).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to put %s to Firestore", id)
+	}
+	return nil
+}
+
+// create reports false without any errors if the document already exists.
+func (c *firestoreCollection) create(ctx context.Context, id string, fields map[string]string) (bool, error) {
+	_, err := c.docs.CreateDocument(c.database+"/documents", c.name, toDocument(fields)).
+		DocumentId(id).Context(ctx).Do()
+	if hasStatus(err, http.StatusConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to create %s in Firestore", id)
+	}
+	return true, nil
+}
+
+// putIf reports false without any errors if the document is updated after updateTime.
+func (c *firestoreCollection) putIf(ctx context.Context, id, updateTime string, fields map[string]string) (bool, error) {
+	_, err := c.docs.Patch(c.docName(id), toDocument(fields)).
+		CurrentDocumentUpdateTime(updateTime).Context(ctx).Do()
+	if hasStatus(err, http.StatusBadRequest) || hasStatus(err, http.StatusConflict) ||
+		hasStatus(err, http.StatusPreconditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to put %s to Firestore", id)
+	}
+	return true, nil
+}
+
+func (c *firestoreCollection) delete(ctx context.Context, id string) error {
+	_, err := c.docs.Delete(c.docName(id)).Context(ctx).Do()
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		return errors.Wrapf(err, "Failed to delete %s from Firestore", id)
+	}
+	return nil
+}
@@ -5,120 +5,197 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/functions/metadata"
-	slack "github.com/ashwanthkumar/slack-go-webhook"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/firestore/v1"
 )
 
+// PubSubMessage is a message of Pub/Sub. Background functions get only Data and Attributes.
 type PubSubMessage struct {
-	Data string `json:"data"`
+	Data        string            `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	MessageID   string            `json:"messageId"`
+	PublishTime time.Time         `json:"publishTime"`
+}
+
+// traceID returns the trace which Pub/Sub propagates in the attributes of the message.
+func (m PubSubMessage) traceID() string {
+	for _, k := range []string{"googclient_traceparent", "traceparent"} {
+		if id := traceID(m.Attributes[k]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// eventLogger returns a Logger labeled with the event in ctx and traced in the project of the topic.
+func eventLogger(ctx context.Context, m PubSubMessage) *Logger {
+	meta, err := metadata.FromContext(ctx)
+	if err != nil {
+		return defaultLogger
+	}
+	var projectID string
+	if ps := strings.Split(meta.Resource.Name, "/"); len(ps) > 1 && ps[0] == "projects" {
+		projectID = ps[1]
+	}
+	return defaultLogger.WithLabels("event_id", meta.EventID).WithTrace(projectID, m.traceID())
 }
 
 const (
 	Service = "Nomos"
 )
 
+// NotifySlack is called with events of the cloud-builds topic.
+// Permanent failures are not returned, so that the event isn't retried.
 func NotifySlack(ctx context.Context, m PubSubMessage) error {
-	config, err := getSlackConfig()
+	ctx = withLogger(ctx, eventLogger(ctx, m))
+	_, err := skipped(notifySlack(ctx, m))
+	return handleError(ctx, err)
+}
+
+// ReplayNotifySlack is NotifySlack for commands replaying events such as notifyslack.
+// It returns permanent failures too, and the reason why the event isn't notified if it is skipped.
+func ReplayNotifySlack(ctx context.Context, m PubSubMessage) (string, error) {
+	ctx = withLogger(ctx, eventLogger(ctx, m))
+	return skipped(notifySlack(ctx, m))
+}
+
+// skippedError tells why the event isn't notified, which isn't a failure.
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string { return e.reason }
+
+// skip logs why the event isn't notified.
+func skip(ctx context.Context, format string, args ...interface{}) error {
+	logger(ctx).Infof(format, args...)
+	return &skippedError{reason: fmt.Sprintf(format, args...)}
+}
+
+// skipped returns the reason of a skipped event instead of the error.
+func skipped(err error) (string, error) {
+	if s, ok := err.(*skippedError); ok {
+		return s.reason, nil
+	}
+	return "", err
+}
+
+func notifySlack(ctx context.Context, m PubSubMessage) error {
+	config, err := getSlackConfig(ctx)
 	if err != nil {
 		return errors.Wrap(err, "Failed to get config about Slack")
 	}
 
 	meta, err := metadata.FromContext(ctx)
 	if err != nil {
-		return errors.Wrap(err, "Failed to get metadata")
+		return errors.Wrap(Permanent(err), "Failed to get metadata")
 	}
-	if meta.Resource.Name != config.WatchingResource() {
-		fmt.Printf("%s is not wathing resource\n", meta.Resource.Name)
-		return nil
+	topicProject, ok := watchedProject(meta.Resource.Name)
+	if !ok {
+		return skip(ctx, "%s is not watching resource", meta.Resource.Name)
 	}
 
 	d, err := base64.StdEncoding.DecodeString(m.Data)
 	if err != nil {
-		return errors.Wrap(err, "Failed to decode base64 data")
+		return errors.Wrap(Permanent(err), "Failed to decode base64 data")
 	}
-	fmt.Printf("Build data: %s\n", string(d))
-
 	build := BuildEvent{}
 	err = json.Unmarshal(d, &build)
 	if err != nil {
-		return errors.Wrap(err, "Failed to decode to JSON")
+		return errors.Wrap(Permanent(err), "Failed to decode to JSON")
 	}
 
-	if !build.HasSource() {
-		fmt.Print("This event isn't source code build")
-		return nil
+	// A function can subscribe to topics of several projects, and push requests may not tell the topic.
+	project := topicProject
+	if project == "" {
+		project = build.ProjectID
+	}
+	config, ok = config.ForProject(project)
+	if !ok {
+		return skip(ctx, "%s is not watching project", project)
 	}
 
-	if !build.AvailableStatus() {
-		fmt.Printf("%s is non available status\n", build.Status)
-		return nil
+	if !build.HasSource() {
+		ctx = withLogger(ctx, logger(ctx).WithLabels("build_id", build.ID, "status", build.Status))
+		return skip(ctx, "This event isn't source code build")
 	}
+	ctx = withLogger(ctx, logger(ctx).WithLabels(
+		"build_id", build.ID, "branch", string(build.Branch()), "status", build.Status))
+	logger(ctx).Debugf("Received %s of build %s", build.Status, build.ID)
 
-	payload := createSlackPayload(build, config)
-	errs := slack.Send(config.SlackWebhookURL(), "", payload)
-	if len(errs) > 0 {
-		return errors.Errorf("Failed to send a message to Slack: %s", errs)
+	// History is recorded even for statuses which aren't notified, not to miss any recoveries.
+	history, err := getHistoryStore(ctx, config)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the history store")
+	}
+	build.Streak, err = recordHistory(ctx, history, build)
+	if err != nil {
+		return errors.Wrap(err, "Failed to record the history of the branch")
 	}
-	fmt.Println("Sent a message to Slack")
 
-	return nil
-}
+	if !config.Notifies(build.Status) && !(config.UpdatesMessage() && build.IsInProgress()) {
+		return skip(ctx, "%s is non available status", build.Status)
+	}
 
-func createSlackPayload(b BuildEvent, c *SlackConfig) slack.Payload {
-	title := "Build Logs"
-	color := b.SlackStatus().Color
-	a := slack.Attachment{
-		Title:      &title,
-		TitleLink:  &b.LogURL,
-		Color:      &color,
-		MarkdownIn: &[]string{"fields"},
-	}
-	a.AddField(slack.Field{
-		Title: "status",
-		Value: fmt.Sprintf("%s %s", b.SlackStatus().Icon, b.Status),
-		Short: true,
-	}).AddField(slack.Field{
-		Title: "Branch",
-		Value: fmt.Sprintf("<%s|%s>", b.Branch().URL(), b.Branch()),
-		Short: true,
-	})
-
-	if b.IsSuccess() && b.IsDeploy() {
-		urls := b.AppURLs(c)
-		for _, u := range urls {
-			a.AddField(slack.Field{
-				Title: u.Title,
-				Value: u.URL,
-			})
+	route := config.File.MatchRoute(build)
+	if route != nil {
+		logger(ctx).Infof("Matched route: %s", route)
+		if route.Drop {
+			return &skippedError{reason: fmt.Sprintf("Dropped by route %s", route.Name)}
+		}
+		if route.OnlyStateChanges && (build.Streak == nil || !build.Streak.Changed) {
+			return skip(ctx, "%s doesn't change the state of %s", build.ID, build.Branch())
 		}
 	}
 
-	a.AddField(slack.Field{
-		Title: "Tag",
-		Value: []string(*b.Tags)[0],
-	})
+	if build.Status == "FAILURE" || build.Status == "TIMEOUT" {
+		build.LogExcerpt = logExcerpt(ctx, config, build)
+	}
 
-	p := slack.Payload{
-		Username:    "Cloud Build",
-		IconEmoji:   ":cloudbuild:",
-		Text:        fmt.Sprintf("%s was built as %s", Service, b.ID),
-		Markdown:    true,
-		Attachments: []slack.Attachment{a},
+	store, err := getDedupStore(ctx, config)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the dedup store")
 	}
 
-	return p
+	return notifyOnce(ctx, store, meta.EventID, build, config.WithRoute(route))
 }
 
+// logExcerpt returns nil if the log can't be read, because the notification is more important.
+func logExcerpt(ctx context.Context, c *SlackConfig, b BuildEvent) []string {
+	s, err := getLogStorage(ctx, c)
+	if err != nil {
+		logger(ctx).Error(err, "Failed to get the log storage")
+		return nil
+	}
+	if s == nil {
+		return nil
+	}
+	lines, err := readLogExcerpt(ctx, s, b, c.LogLines, c.redactPatterns())
+	if err != nil {
+		logger(ctx).Error(err, "Failed to read the log of "+b.ID)
+		return nil
+	}
+	return lines
+}
+
+// BackupFirestore is called with events of the backup-firestore topic.
+// Permanent failures are not returned, so that the event isn't retried.
 func BackupFirestore(ctx context.Context, m PubSubMessage) error {
-	fmt.Printf("Message: %#v\n", m)
+	ctx = withLogger(ctx, eventLogger(ctx, m))
+	return handleError(ctx, backupFirestore(ctx, m))
+}
+
+func backupFirestore(ctx context.Context, m PubSubMessage) error {
+	logger(ctx).Debugf("Message: %s", m.Data)
 
 	config, err := getFirestoreConfig()
 	if err != nil {
-		return errors.Wrap(err, "Failed to get config about Firestore")
+		return errors.Wrap(Permanent(err), "Failed to get config about Firestore")
 	}
 
 	client, err := google.DefaultClient(ctx,
@@ -140,9 +217,9 @@ func BackupFirestore(ctx context.Context, m PubSubMessage) error {
 		config.DatabaseName(), req,
 	).Context(ctx).Do()
 	if err != nil {
-		return errors.Wrap(err, "Failed to export Firestore")
+		return errors.Wrap(classifyGoogleAPIError(err), "Failed to export Firestore")
 	}
-	fmt.Printf("Successful Response: %#v\n", res)
+	logger(ctx).Infof("Started to export Firestore: %s", res.Name)
 
 	return nil
 }
@@ -1,18 +1,24 @@
 module github.com/bm-sms/nomos/gcf
 
+go 1.17
+
 require (
 	cloud.google.com/go v0.36.0
-	github.com/ashwanthkumar/slack-go-webhook v0.0.0-20181208062437-4a19b1a876b7
-	github.com/elazarl/goproxy v0.0.0-20181111060418-2ce16c963a8a // indirect
-	github.com/google/go-cmp v0.2.0
-	github.com/jtolds/gls v4.2.1+incompatible // indirect
+	github.com/cloudevents/sdk-go/v2 v2.14.0
+	github.com/google/go-cmp v0.5.0
 	github.com/kelseyhightower/envconfig v1.3.0
-	github.com/moul/http2curl v1.0.0 // indirect
-	github.com/parnurzeal/gorequest v0.2.15 // indirect
-	github.com/pkg/errors v0.8.1
-	github.com/smartystreets/assertions v0.0.0-20190116191733-b6c0e53d7304 // indirect
-	github.com/smartystreets/goconvey v0.0.0-20181108003508-044398e4856c // indirect
+	github.com/pkg/errors v0.9.1
 	github.com/tenntenn/sync v0.0.0-20180624231837-38c46c280d9d
 	golang.org/x/oauth2 v0.0.0-20190130055435-99b60b757ec1
 	google.golang.org/api v0.1.0
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/golang/protobuf v1.2.0 // indirect
+	github.com/json-iterator/go v1.1.10 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v0.0.0-20180701023420-4b7aa43c6742 // indirect
+	golang.org/x/net v0.0.0-20190108225652-1e06a53dbb7e // indirect
+	google.golang.org/appengine v1.4.0 // indirect
 )
@@ -0,0 +1,201 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+)
+
+// BuildStreak tells how a build changes the result of its branch.
+type BuildStreak struct {
+	// Changed is true if the branch is broken or fixed by the build.
+	Changed bool `json:"changed"`
+	// Failures is the number of failures in a row including the build.
+	Failures int `json:"failures"`
+	// BrokenSince is the first failed build in a row, which is empty if the branch passes.
+	BrokenSince string    `json:"brokenSince"`
+	BrokenAt    time.Time `json:"brokenAt"`
+	// FixedAfter is how long the branch had been broken until the build fixed it.
+	FixedAfter time.Duration `json:"fixedAfter"`
+}
+
+func (s *BuildStreak) IsBroken() bool {
+	return s.Failures > 0
+}
+
+func (s *BuildStreak) IsFixed() bool {
+	return s.Failures == 0 && s.Changed
+}
+
+// BranchHistory is the last finished build of a branch.
+type BranchHistory struct {
+	BuildID    string      `json:"buildId"`
+	Status     string      `json:"status"`
+	FinishTime time.Time   `json:"finishTime"`
+	Streak     BuildStreak `json:"streak"`
+}
+
+// HistoryStore keeps BranchHistory of each branch.
+// Get returns nil without any errors for a branch which has no builds.
+type HistoryStore interface {
+	Get(ctx context.Context, branch string) (*BranchHistory, error)
+	Put(ctx context.Context, branch string, h BranchHistory) error
+}
+
+var (
+	historyStore     HistoryStore
+	onceHistoryStore try.Once
+)
+
+// getHistoryStore returns nil if build history is disabled.
+func getHistoryStore(ctx context.Context, c *SlackConfig) (HistoryStore, error) {
+	err := onceHistoryStore.Try(func() error {
+		var err error
+		historyStore, err = newHistoryStore(ctx, c)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return historyStore, nil
+}
+
+func newHistoryStore(ctx context.Context, c *SlackConfig) (HistoryStore, error) {
+	switch c.HistoryStore {
+	case "none":
+		return nil, nil
+	case "memory":
+		return NewMemoryHistoryStore(), nil
+	case "firestore":
+		return NewFirestoreHistoryStore(ctx, c.homeProject(), c.HistoryCollection)
+	}
+	return nil, errors.Errorf("%s is unknown history store", c.HistoryStore)
+}
+
+func isFailure(status string) bool {
+	switch status {
+	case "FAILURE", "INTERNAL_ERROR", "TIMEOUT":
+		return true
+	}
+	return false
+}
+
+func historyKey(b BuildEvent) string {
+	return fmt.Sprintf("%s:%s", b.ProjectID, b.Branch())
+}
+
+// recordHistory updates the history of the branch with b and returns its streak.
+// It returns nil for builds which don't pass or fail, builds without branches and builds older than the last one.
+// Redelivered builds get the same streak as the first time.
+func recordHistory(ctx context.Context, s HistoryStore, b BuildEvent) (*BuildStreak, error) {
+	if s == nil || (b.Status != "SUCCESS" && !isFailure(b.Status)) || b.Branch() == "" {
+		return nil, nil
+	}
+
+	key := historyKey(b)
+	prev, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get the history of %s", key)
+	}
+	if prev != nil && prev.BuildID == b.ID {
+		return &prev.Streak, nil
+	}
+	if prev != nil && b.FinishTime.Before(prev.FinishTime) {
+		logger(ctx).Infof("%s finished before the last build %s of %s", b.ID, prev.BuildID, key)
+		return nil, nil
+	}
+
+	streak := nextStreak(prev, b)
+	h := BranchHistory{BuildID: b.ID, Status: b.Status, FinishTime: b.FinishTime, Streak: streak}
+	if err := s.Put(ctx, key, h); err != nil {
+		return nil, errors.Wrapf(err, "Failed to put the history of %s", key)
+	}
+	return &streak, nil
+}
+
+func nextStreak(prev *BranchHistory, b BuildEvent) BuildStreak {
+	wasBroken := prev != nil && prev.Streak.IsBroken()
+
+	if !isFailure(b.Status) {
+		if !wasBroken {
+			return BuildStreak{}
+		}
+		return BuildStreak{Changed: true, FixedAfter: b.FinishTime.Sub(prev.Streak.BrokenAt)}
+	}
+
+	if !wasBroken {
+		return BuildStreak{Changed: true, Failures: 1, BrokenSince: b.ID, BrokenAt: b.FinishTime}
+	}
+	s := prev.Streak
+	s.Changed = false
+	s.Failures++
+	return s
+}
+
+// MemoryHistoryStore keeps history while the instance of the function is alive.
+type MemoryHistoryStore struct {
+	mu        sync.Mutex
+	histories map[string]BranchHistory
+}
+
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{histories: map[string]BranchHistory{}}
+}
+
+func (s *MemoryHistoryStore) Get(ctx context.Context, branch string) (*BranchHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.histories[branch]
+	if !ok {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func (s *MemoryHistoryStore) Put(ctx context.Context, branch string, h BranchHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.histories[branch] = h
+	return nil
+}
+
+// FirestoreHistoryStore keeps history as documents of a Firestore collection.
+// A branch name is escaped for the document ID, because it can't have "/".
+type FirestoreHistoryStore struct {
+	col *firestoreCollection
+}
+
+func NewFirestoreHistoryStore(ctx context.Context, projectID, collection string) (*FirestoreHistoryStore, error) {
+	col, err := newFirestoreCollection(ctx, projectID, collection)
+	if err != nil {
+		return nil, err
+	}
+	return &FirestoreHistoryStore{col: col}, nil
+}
+
+func (s *FirestoreHistoryStore) Get(ctx context.Context, branch string) (*BranchHistory, error) {
+	fields, err := s.col.get(ctx, url.QueryEscape(branch))
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	h := &BranchHistory{}
+	if err := json.Unmarshal([]byte(fields["history"]), h); err != nil {
+		return nil, errors.Wrapf(err, "Failed to decode the history of %s", branch)
+	}
+	return h, nil
+}
+
+func (s *FirestoreHistoryStore) Put(ctx context.Context, branch string, h BranchHistory) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.col.put(ctx, url.QueryEscape(branch), map[string]string{"history": string(b)})
+}
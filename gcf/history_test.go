@@ -0,0 +1,81 @@
+package gcf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordHistory(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryHistoryStore()
+	start := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	build := func(id, status string, finished time.Duration) BuildEvent {
+		return BuildEvent{
+			ID:            id,
+			ProjectID:     "nomos-sms",
+			Status:        status,
+			FinishTime:    start.Add(finished),
+			Source:        &BuildSource{RepoSource: &BuildRepoSource{BranchName: "master"}},
+			Substitutions: &BuildSubstitutions{BranchName: "master"},
+		}
+	}
+
+	steps := []struct {
+		name  string
+		build BuildEvent
+		want  *BuildStreak
+	}{
+		{"First success", build("b1", "SUCCESS", 0), &BuildStreak{}},
+		{"In progress", build("b2", "WORKING", 0), nil},
+		{"Broken", build("b2", "FAILURE", time.Hour),
+			&BuildStreak{Changed: true, Failures: 1, BrokenSince: "b2", BrokenAt: start.Add(time.Hour)}},
+		{"Still broken", build("b3", "TIMEOUT", 2*time.Hour),
+			&BuildStreak{Failures: 2, BrokenSince: "b2", BrokenAt: start.Add(time.Hour)}},
+		{"Redelivered", build("b3", "TIMEOUT", 2*time.Hour),
+			&BuildStreak{Failures: 2, BrokenSince: "b2", BrokenAt: start.Add(time.Hour)}},
+		{"Cancelled", build("b4", "CANCELLED", 150*time.Minute), nil},
+		{"Older build", build("b0", "SUCCESS", 90*time.Minute), nil},
+		{"Fixed", build("b5", "SUCCESS", 3*time.Hour), &BuildStreak{Changed: true, FixedAfter: 2 * time.Hour}},
+		{"Still fixed", build("b6", "SUCCESS", 4*time.Hour), &BuildStreak{}},
+	}
+	for _, st := range steps {
+		got, err := recordHistory(ctx, s, st.build)
+		if err != nil {
+			t.Fatalf("%s: recordHistory() error = %v", st.name, err)
+		}
+		if diff := cmp.Diff(got, st.want); diff != "" {
+			t.Errorf("%s: recordHistory() = %v, want %v, differs: (-got +want;\n%s)", st.name, got, st.want, diff)
+		}
+	}
+
+	h, err := s.Get(ctx, "nomos-sms:master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == nil || h.BuildID != "b6" {
+		t.Errorf("MemoryHistoryStore.Get() = %v, want the history of b6", h)
+	}
+}
+
+func TestRecordHistory_disabled(t *testing.T) {
+	got, err := recordHistory(context.Background(), nil, BuildEvent{ID: "b1", Status: "FAILURE"})
+	if got != nil || err != nil {
+		t.Errorf("recordHistory() = %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestRecordHistory_withoutBranch(t *testing.T) {
+	s := NewMemoryHistoryStore()
+	e := BuildEvent{
+		ID:     "b1",
+		Status: "FAILURE",
+		Source: &BuildSource{StorageSource: &BuildStorageSource{Bucket: "nomos-sms_cloudbuild", Object: "source/1550106000.tgz"}},
+	}
+	got, err := recordHistory(context.Background(), s, e)
+	if got != nil || err != nil {
+		t.Errorf("recordHistory() = %v, %v, want nil, nil", got, err)
+	}
+}
@@ -0,0 +1,83 @@
+package gcf
+
+import (
+	"time"
+)
+
+// Languages of messages.
+const (
+	English  = "en"
+	Japanese = "ja"
+)
+
+// timestampLayout is how times of builds are shown in TIME_ZONE.
+const timestampLayout = "2006-01-02 15:04:05 MST"
+
+// catalog has texts and formats of messages in each language except English, keyed by English ones.
+var catalog = map[string]map[string]string{
+	Japanese: {
+		"Status":       "ステータス",
+		"Branch":       "ブランチ",
+		"Git Tag":      "Git タグ",
+		"Origin":       "ソース",
+		"Commit":       "コミット",
+		"Pull Request": "プルリクエスト",
+		"Failed Step":  "失敗したステップ",
+		"Streak":       "連続",
+		"Tag":          "タグ",
+		"Build Logs":   "ビルドログ",
+		"Retry Build":  "再実行",
+		"Cancel Build": "キャンセル",
+
+		"Project: `%s`":  "プロジェクト: `%s`",
+		"Trigger: `%s`":  "トリガー: `%s`",
+		"Queued: %s":     "待ち時間: %s",
+		"Ran: %s":        "実行時間: %s",
+		"Started at %s":  "開始: %s",
+		"Finished at %s": "完了: %s",
+		"%s after %s":    "%s (%s 経過)",
+
+		"Fixed after %s":                           "%s ぶりに復旧",
+		"Broken by this build":                     "このビルドで失敗",
+		"Broken since build <%s|%s> (%d failures)": "ビルド <%s|%s> から失敗中 (%d 回)",
+
+		":repeat: Retried by <@%s> as <%s|%s>": ":repeat: <@%s> が <%s|%s> として再実行",
+		":no_entry: Cancelled by <@%s>":        ":no_entry: <@%s> がキャンセル",
+
+		DefaultMessageText: "{{.Service}} を {{.ID}} としてビルドしました",
+	},
+}
+
+func validLanguage(lang string) bool {
+	_, ok := catalog[lang]
+	return ok || lang == English
+}
+
+// translate returns s in the language, or s itself if the catalog doesn't have it.
+func translate(lang, s string) string {
+	if t, ok := catalog[lang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// language returns the language of messages, which routes and channels can select.
+func (c *SlackConfig) language() string {
+	if c.Language == "" {
+		return English
+	}
+	return c.Language
+}
+
+func (c *SlackConfig) translate(s string) string {
+	return translate(c.language(), s)
+}
+
+// timestamp formats t in TIME_ZONE, or UTC if it isn't loaded.
+func (c *SlackConfig) timestamp(t time.Time) string {
+	loc := c.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(timestampLayout)
+}
@@ -0,0 +1,257 @@
+package gcf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+)
+
+// maxSlackRequestAge rejects replayed requests of Slack.
+const maxSlackRequestAge = 5 * time.Minute
+
+// SlackInteractionPayload is the payload of block_actions which Slack sends on clicks of buttons.
+// See https://api.slack.com/reference/interaction-payloads/block-actions
+type SlackInteractionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string       `json:"response_url"`
+	Message     SlackMessage `json:"message"`
+}
+
+// SlackInteractionHandler retries and cancels builds with buttons on messages of Slack,
+// then updates the message with who clicked it.
+type SlackInteractionHandler struct {
+	SigningSecret string
+	// Secrets resolves SigningSecret on each request if it is a reference such as secret://slack-signing-secret,
+	// so that rotated secrets are used without redeploys.
+	Secrets    *SecretCache
+	Controller BuildController
+	Client     *SlackClient
+
+	now func() time.Time
+}
+
+var (
+	slackInteractionHandler     *SlackInteractionHandler
+	onceSlackInteractionHandler try.Once
+)
+
+// SlackInteraction is the HTTP function of the Request URL of interactivity of the Slack app.
+func SlackInteraction(w http.ResponseWriter, r *http.Request) {
+	err := onceSlackInteractionHandler.Try(func() error {
+		var err error
+		slackInteractionHandler, err = NewSlackInteractionHandler(r.Context())
+		return err
+	})
+	if err != nil {
+		defaultLogger.Error(err, "Failed to create the handler of interactions")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slackInteractionHandler.ServeHTTP(w, r)
+}
+
+// NewSlackInteractionHandler returns SlackInteractionHandler configured with environment variables.
+func NewSlackInteractionHandler(ctx context.Context) (*SlackInteractionHandler, error) {
+	c, err := getSlackConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get config about Slack")
+	}
+	if !c.Interactive() {
+		return nil, errors.New("SLACK_SIGNING_SECRET is required to verify interactions")
+	}
+
+	secret := c.SlackSigningSecret
+	var secrets *SecretCache
+	if name, ok := c.secretRefs["SLACK_SIGNING_SECRET"]; ok {
+		secret = SecretScheme + name
+		secrets, err = getSecretCache(ctx, c)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get the secret provider")
+		}
+	}
+
+	controller, err := NewCloudBuildController(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SlackInteractionHandler{
+		SigningSecret: secret,
+		Secrets:       secrets,
+		Controller:    controller,
+		Client:        defaultSlackClient,
+		now:           time.Now,
+	}, nil
+}
+
+func (h *SlackInteractionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	secret, err := h.signingSecret(r.Context())
+	if err != nil {
+		defaultLogger.Error(err, "Failed to get the signing secret")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	err = verifySlackSignature(secret, r.Header.Get("X-Slack-Request-Timestamp"),
+		r.Header.Get("X-Slack-Signature"), body, h.now())
+	if err != nil {
+		defaultLogger.Warningf("Unauthenticated interaction: %v", err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	p := SlackInteractionPayload{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &p); err != nil {
+		defaultLogger.Warningf("Invalid interaction: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.handle(r.Context(), p); err != nil {
+		defaultLogger.Error(err, "Failed to handle the interaction")
+		msg := SlackMessage{ResponseType: "ephemeral", Text: fmt.Sprintf("Failed: %v", errors.Cause(err))}
+		if rerr := h.Client.PostWebhook(r.Context(), p.ResponseURL, msg); rerr != nil {
+			defaultLogger.Error(rerr, "Failed to respond the failure")
+		}
+	}
+	// Slack shows an error unless it gets 200 in 3 seconds, even if the action failed.
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *SlackInteractionHandler) signingSecret(ctx context.Context) (string, error) {
+	if h.Secrets == nil || !strings.HasPrefix(h.SigningSecret, SecretScheme) {
+		return h.SigningSecret, nil
+	}
+	name := strings.TrimPrefix(h.SigningSecret, SecretScheme)
+	v, err := h.Secrets.Get(ctx, name)
+	return v, errors.Wrapf(err, "Failed to get secret %s of SLACK_SIGNING_SECRET", name)
+}
+
+func (h *SlackInteractionHandler) handle(ctx context.Context, p SlackInteractionPayload) error {
+	if p.Type != "block_actions" {
+		return nil
+	}
+
+	for _, a := range p.Actions {
+		if a.ActionID != RetryBuildAction && a.ActionID != CancelBuildAction {
+			continue
+		}
+		projectID, buildID, lang, err := parseBuildActionValue(a.Value)
+		if err != nil {
+			return err
+		}
+
+		var note string
+		var next []interface{}
+		switch a.ActionID {
+		case RetryBuildAction:
+			newID, err := h.Controller.Retry(ctx, projectID, buildID)
+			if err != nil {
+				return err
+			}
+			note = fmt.Sprintf(translate(lang, ":repeat: Retried by <@%s> as <%s|%s>"), p.User.ID, buildConsoleURL(projectID, newID), newID)
+			next = []interface{}{cancelButton(lang, projectID, newID)}
+		case CancelBuildAction:
+			if err := h.Controller.Cancel(ctx, projectID, buildID); err != nil {
+				return err
+			}
+			note = fmt.Sprintf(translate(lang, ":no_entry: Cancelled by <@%s>"), p.User.ID)
+		}
+		defaultLogger.WithLabels("build_id", buildID).Infof("%s by %s", a.ActionID, p.User.Username)
+
+		msg := updateInteractedMessage(p.Message, note, next)
+		msg.ReplaceOriginal = true
+		return h.Client.PostWebhook(ctx, p.ResponseURL, msg)
+	}
+	return nil
+}
+
+// updateInteractedMessage adds the note to the message and replaces the buttons to retry and cancel with next.
+func updateInteractedMessage(msg SlackMessage, note string, next []interface{}) SlackMessage {
+	for i := range msg.Attachments {
+		var blocks []SlackBlock
+		for _, b := range msg.Attachments[i].Blocks {
+			if b.Type != "actions" {
+				blocks = append(blocks, b)
+				continue
+			}
+			var elements []interface{}
+			for _, e := range b.Elements {
+				if isBuildActionElement(e) {
+					continue
+				}
+				elements = append(elements, e)
+			}
+			text := mrkdwn(note)
+			blocks = append(blocks,
+				SlackBlock{Type: "context", Elements: []interface{}{text}},
+				SlackBlock{Type: "actions", Elements: append(elements, next...)},
+			)
+		}
+		msg.Attachments[i].Blocks = blocks
+	}
+	return msg
+}
+
+// isBuildActionElement reports whether the element decoded from a payload is a button to retry or cancel.
+func isBuildActionElement(e interface{}) bool {
+	m, ok := e.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	id, _ := m["action_id"].(string)
+	return id == RetryBuildAction || id == CancelBuildAction
+}
+
+// verifySlackSignature verifies the request with the signing secret.
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Errorf("%s is invalid timestamp", timestamp)
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > maxSlackRequestAge || d < -maxSlackRequestAge {
+		return errors.Errorf("Request at %s is too old", time.Unix(ts, 0))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return errors.New("Signature doesn't match")
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package gcf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type fakeBuildController struct {
+	newID string
+	err   error
+	calls []string
+}
+
+func (c *fakeBuildController) Retry(ctx context.Context, projectID, buildID string) (string, error) {
+	c.calls = append(c.calls, "retry "+projectID+"/"+buildID)
+	return c.newID, c.err
+}
+
+func (c *fakeBuildController) Cancel(ctx context.Context, projectID, buildID string) error {
+	c.calls = append(c.calls, "cancel "+projectID+"/"+buildID)
+	return c.err
+}
+
+func signSlackRequest(secret string, ts time.Time, body string) (string, string) {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	return timestamp, "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Now()
+	body := []byte("payload=%7B%7D")
+	ts, sig := signSlackRequest("secret", now, string(body))
+	oldTS, oldSig := signSlackRequest("secret", now.Add(-10*time.Minute), string(body))
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		wantErr   bool
+	}{
+		{"Valid", "secret", ts, sig, false},
+		{"Other secret", "other", ts, sig, true},
+		{"No signature", "secret", ts, "", true},
+		{"Too old", "secret", oldTS, oldSig, true},
+		{"Invalid timestamp", "secret", "now", sig, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := verifySlackSignature(tt.secret, tt.timestamp, tt.signature, body, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySlackSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSlackInteractionHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		actionID     string
+		value        string
+		controller   *fakeBuildController
+		sign         bool
+		wantStatus   int
+		wantCalls    []string
+		wantContains []string
+		wantReplace  bool
+	}{
+		{
+			"Retry", RetryBuildAction, "nomos-sms/build-id/en", &fakeBuildController{newID: "new-build-id"}, true, http.StatusOK,
+			[]string{"retry nomos-sms/build-id"},
+			[]string{"Retried by <@U123>", "new-build-id", `"action_id":"cancel_build"`, `"value":"nomos-sms/new-build-id/en"`, "Build Log"},
+			true,
+		},
+		{
+			"Retry in Japanese", RetryBuildAction, "nomos-sms/build-id/ja", &fakeBuildController{newID: "new-build-id"}, true, http.StatusOK,
+			[]string{"retry nomos-sms/build-id"},
+			[]string{"<@U123> が", `"text":"キャンセル"`, `"value":"nomos-sms/new-build-id/ja"`},
+			true,
+		},
+		{
+			"Cancel posted without languages", CancelBuildAction, "nomos-sms/build-id", &fakeBuildController{}, true, http.StatusOK,
+			[]string{"cancel nomos-sms/build-id"},
+			[]string{"Cancelled by <@U123>", "Build Log"},
+			true,
+		},
+		{
+			"Failure is told only to the user", RetryBuildAction, "nomos-sms/build-id/en", &fakeBuildController{err: errors.New("denied")}, true, http.StatusOK,
+			[]string{"retry nomos-sms/build-id"},
+			[]string{`"response_type":"ephemeral"`, "denied"},
+			false,
+		},
+		{
+			"Unsigned", RetryBuildAction, "nomos-sms/build-id/en", &fakeBuildController{}, false, http.StatusUnauthorized,
+			nil, nil, false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var responded SlackMessage
+			var raw string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				// Slack accepts < and > escaped by encoding/json.
+				raw = strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(b))
+				if err := json.Unmarshal(b, &responded); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer ts.Close()
+
+			payload := map[string]interface{}{
+				"type":         "block_actions",
+				"user":         map[string]string{"id": "U123", "username": "iwata"},
+				"actions":      []map[string]string{{"action_id": tt.actionID, "value": tt.value}},
+				"response_url": ts.URL,
+				"message": SlackMessage{Attachments: []SlackAttachment{{Blocks: []SlackBlock{{
+					Type: "actions",
+					Elements: []interface{}{
+						SlackButton{Type: "button", Text: plainText("Build Log"), URL: "https://example.com/"},
+						retryButton(English, "nomos-sms", "build-id"),
+					},
+				}}}}},
+			}
+			body := url.Values{"payload": {toJSON(t, payload)}}.Encode()
+
+			now := time.Now()
+			h := &SlackInteractionHandler{
+				SigningSecret: "secret",
+				Controller:    tt.controller,
+				Client:        &SlackClient{},
+				now:           func() time.Time { return now },
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			if tt.sign {
+				timestamp, sig := signSlackRequest("secret", now, body)
+				req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+				req.Header.Set("X-Slack-Signature", sig)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("SlackInteractionHandler responded %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if fmt.Sprint(tt.controller.calls) != fmt.Sprint(tt.wantCalls) {
+				t.Errorf("SlackInteractionHandler called %v, want %v", tt.controller.calls, tt.wantCalls)
+			}
+			for _, s := range tt.wantContains {
+				if !strings.Contains(raw, s) {
+					t.Errorf("SlackInteractionHandler responded %s, want it to contain %s", raw, s)
+				}
+			}
+			if responded.ReplaceOriginal != tt.wantReplace {
+				t.Errorf("SlackInteractionHandler responded replace_original %v, want %v", responded.ReplaceOriginal, tt.wantReplace)
+			}
+			if tt.wantReplace && strings.Contains(raw, RetryBuildAction) {
+				t.Errorf("SlackInteractionHandler responded %s, want no retry buttons", raw)
+			}
+		})
+	}
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSlackInteractionHandler_rotatedSecret(t *testing.T) {
+	secrets := MemorySecretProvider{"slack-signing-secret": "old"}
+	now := time.Now()
+	h := &SlackInteractionHandler{
+		SigningSecret: "secret://slack-signing-secret",
+		Secrets:       NewSecretCache(secrets, 0),
+		Controller:    &fakeBuildController{},
+		Client:        &SlackClient{},
+		now:           func() time.Time { return now },
+	}
+
+	steps := []struct {
+		name       string
+		rotated    string
+		signedWith string
+		wantStatus int
+	}{
+		{"Current secret", "", "old", http.StatusOK},
+		{"Rotated secret", "new", "new", http.StatusOK},
+		{"Previous secret", "", "old", http.StatusUnauthorized},
+	}
+	for _, st := range steps {
+		if st.rotated != "" {
+			secrets["slack-signing-secret"] = st.rotated
+		}
+		body := url.Values{"payload": {`{"type":"view_submission"}`}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		timestamp, sig := signSlackRequest(st.signedWith, now, body)
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", sig)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != st.wantStatus {
+			t.Errorf("%s: SlackInteractionHandler responded %d, want %d", st.name, rec.Code, st.wantStatus)
+		}
+	}
+}
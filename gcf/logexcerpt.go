@@ -0,0 +1,256 @@
+package gcf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/storage/v1"
+)
+
+// Redacted replaces secrets in logs.
+const Redacted = "[REDACTED]"
+
+// defaultRedactPatterns match secrets which often leak to logs of builds.
+// Patterns in the config file are added to them.
+// Only the group named "secret" is redacted if a pattern has it, and the whole match otherwise.
+var defaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9/_-]+`),
+	regexp.MustCompile(`xox[abposr]-[A-Za-z0-9-]+`),
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`(?i)(?:bearer|basic)\s+(?P<secret>[A-Za-z0-9._~+/=-]+)`),
+	regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api[_-]?key)["']?\s*[:=]\s*["']?(?P<secret>[^\s"']+)`),
+	// Keys in a line such as of JSON, and the rest of keys are redacted by redactPrivateKeys.
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?:.*?-----END [A-Z ]*PRIVATE KEY-----|.*)`),
+}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// LogStorage reads logs of builds from their logs bucket.
+type LogStorage interface {
+	Open(ctx context.Context, bucket, object string) (io.ReadCloser, error)
+}
+
+var (
+	logStorage     LogStorage
+	onceLogStorage try.Once
+)
+
+// getLogStorage returns nil if logs are not read.
+func getLogStorage(ctx context.Context, c *SlackConfig) (LogStorage, error) {
+	err := onceLogStorage.Try(func() error {
+		var err error
+		logStorage, err = newLogStorage(ctx, c)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return logStorage, nil
+}
+
+func newLogStorage(ctx context.Context, c *SlackConfig) (LogStorage, error) {
+	if c.LogLines <= 0 {
+		return nil, nil
+	}
+	switch c.LogStorage {
+	case "none":
+		return nil, nil
+	case "gcs":
+		return NewGCSLogStorage(ctx)
+	case "dir":
+		return &DirLogStorage{Dir: c.LogDir}, nil
+	}
+	return nil, errors.Errorf("%s is unknown log storage", c.LogStorage)
+}
+
+// GCSLogStorage reads logs from Cloud Storage.
+type GCSLogStorage struct {
+	objects *storage.ObjectsService
+}
+
+func NewGCSLogStorage(ctx context.Context) (*GCSLogStorage, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_only")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a Google client")
+	}
+
+	svc, err := storage.New(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create Cloud Storage service")
+	}
+	return &GCSLogStorage{objects: storage.NewObjectsService(svc)}, nil
+}
+
+func (s *GCSLogStorage) Open(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
+	res, err := s.objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, errors.Wrapf(classifyGoogleAPIError(err), "Failed to download gs://%s/%s", bucket, object)
+	}
+	return res.Body, nil
+}
+
+// DirLogStorage reads logs from Dir/<bucket>/<object> instead of Cloud Storage.
+type DirLogStorage struct {
+	Dir string
+}
+
+func (s *DirLogStorage) Open(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.Dir, bucket, filepath.FromSlash(object)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open %s/%s", bucket, object)
+	}
+	return f, nil
+}
+
+// logObject returns where the log of the build is. LogsBucket can have a path after the bucket.
+func (e BuildEvent) logObject() (string, string, bool) {
+	if e.LogsBucket == "" {
+		return "", "", false
+	}
+	p := strings.TrimPrefix(e.LogsBucket, "gs://")
+	object := fmt.Sprintf("log-%s.txt", e.ID)
+	if i := strings.Index(p, "/"); i >= 0 {
+		if dir := strings.Trim(p[i+1:], "/"); dir != "" {
+			object = dir + "/" + object
+		}
+		p = p[:i]
+	}
+	return p, object, true
+}
+
+// stepLogPrefixes returns prefixes of lines written by the i-th step, such as
+// `Step #3 - "deploy": ` with the ID and `Step #3: ` without it.
+func stepLogPrefixes(i int, s *BuildStep) []string {
+	if s.ID != "" {
+		return []string{fmt.Sprintf("Step #%d - %q: ", i, s.ID), fmt.Sprintf("Step #%d - %q:", i, s.ID)}
+	}
+	return []string{fmt.Sprintf("Step #%d: ", i), fmt.Sprintf("Step #%d:", i)}
+}
+
+// readLogExcerpt returns the last n lines of the failed step, or of the whole log without any failed steps.
+// The prefixes of steps are trimmed and secrets are redacted with patterns.
+func readLogExcerpt(ctx context.Context, s LogStorage, e BuildEvent, n int, patterns []*regexp.Regexp) ([]string, error) {
+	bucket, object, ok := e.logObject()
+	if !ok || n <= 0 {
+		return nil, nil
+	}
+
+	r, err := s.Open(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var prefixes []string
+	if step := e.FailedStep(); step != nil {
+		for i := range e.Steps {
+			if &e.Steps[i] == step {
+				prefixes = stepLogPrefixes(i, step)
+			}
+		}
+	}
+
+	lines := make([]string, 0, n)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line, ok := trimLogPrefix(sc.Text(), prefixes)
+		if !ok {
+			continue
+		}
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "Failed to read %s/%s", bucket, object)
+	}
+
+	redactPrivateKeys(lines)
+	for i := range lines {
+		lines[i] = redact(lines[i], patterns)
+	}
+	return lines, nil
+}
+
+// redactPrivateKeys redacts lines of private keys from BEGIN to END.
+// Lines before END without BEGIN are redacted too, because the excerpt can start in the middle of a key.
+func redactPrivateKeys(lines []string) {
+	inKey := false
+	for i, line := range lines {
+		begin := privateKeyBegin.FindStringIndex(line)
+		end := privateKeyEnd.FindStringIndex(line)
+		switch {
+		case inKey:
+			lines[i] = Redacted
+			inKey = end == nil
+		case begin != nil:
+			// A key in the line is left to the pattern.
+			if end == nil || end[0] < begin[0] {
+				lines[i] = line[:begin[0]] + Redacted
+				inKey = true
+			}
+		case end != nil:
+			for j := 0; j <= i; j++ {
+				lines[j] = Redacted
+			}
+		}
+	}
+}
+
+// trimLogPrefix reports whether the line has any of prefixes and trims it.
+// Any lines are accepted without prefixes.
+func trimLogPrefix(line string, prefixes []string) (string, bool) {
+	if len(prefixes) == 0 {
+		return line, true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(line, p) {
+			return strings.TrimPrefix(line, p), true
+		}
+	}
+	return "", false
+}
+
+func redact(line string, patterns []*regexp.Regexp) string {
+	for _, p := range patterns {
+		g := p.SubexpIndex("secret")
+		if g < 0 {
+			line = p.ReplaceAllString(line, Redacted)
+			continue
+		}
+
+		var sb strings.Builder
+		last := 0
+		for _, m := range p.FindAllStringSubmatchIndex(line, -1) {
+			if m[2*g] < 0 {
+				continue
+			}
+			sb.WriteString(line[last:m[2*g]])
+			sb.WriteString(Redacted)
+			last = m[2*g+1]
+		}
+		sb.WriteString(line[last:])
+		line = sb.String()
+	}
+	return line
+}
+
+// redactPatterns returns the default patterns and ones in the config file.
+func (c *SlackConfig) redactPatterns() []*regexp.Regexp {
+	return append(append([]*regexp.Regexp{}, defaultRedactPatterns...), c.File.redactPatterns...)
+}
@@ -0,0 +1,141 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Severity is the severity of a log entry of Cloud Logging.
+type Severity string
+
+const (
+	Debug   Severity = "DEBUG"
+	Info    Severity = "INFO"
+	Warning Severity = "WARNING"
+	Error   Severity = "ERROR"
+)
+
+// reportedErrorEvent makes Error Reporting pick up an entry.
+// See https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const reportedErrorEvent = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// LogEntry is a line of structured logging which Cloud Logging parses.
+// See https://cloud.google.com/logging/docs/structured-logging
+type LogEntry struct {
+	Severity Severity          `json:"severity"`
+	Message  string            `json:"message"`
+	Labels   map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+	Trace    string            `json:"logging.googleapis.com/trace,omitempty"`
+	Type     string            `json:"@type,omitempty"`
+}
+
+// Logger writes LogEntry as JSON lines with labels and the trace.
+// It is immutable, and With* methods return a copy.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	labels map[string]string
+	trace  string
+}
+
+// defaultLogger writes to stdout, which Cloud Functions sends to Cloud Logging.
+var defaultLogger = NewLogger(os.Stdout)
+
+func NewLogger(out io.Writer) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: out}
+}
+
+// WithLabels returns a Logger which adds the labels given as key-value pairs.
+// Empty values are omitted.
+func (l *Logger) WithLabels(kvs ...string) *Logger {
+	c := *l
+	c.labels = make(map[string]string, len(l.labels)+len(kvs)/2)
+	for k, v := range l.labels {
+		c.labels[k] = v
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if kvs[i+1] != "" {
+			c.labels[kvs[i]] = kvs[i+1]
+		}
+	}
+	return &c
+}
+
+// WithTrace returns a Logger which relates entries to the trace in the project.
+func (l *Logger) WithTrace(projectID, traceID string) *Logger {
+	c := *l
+	c.trace = ""
+	if projectID != "" && traceID != "" {
+		c.trace = fmt.Sprintf("projects/%s/traces/%s", projectID, traceID)
+	}
+	return &c
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(LogEntry{Severity: Debug, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(LogEntry{Severity: Info, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.log(LogEntry{Severity: Warning, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error logs err with the stack of pkg/errors for Error Reporting.
+func (l *Logger) Error(err error, msg string) {
+	l.log(LogEntry{Severity: Error, Message: fmt.Sprintf("%s: %+v", msg, err), Type: reportedErrorEvent})
+}
+
+func (l *Logger) log(e LogEntry) {
+	e.Labels = l.labels
+	e.Trace = l.trace
+	b, err := json.Marshal(e)
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"severity":"ERROR","message":%q}`, err.Error()))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// An entry can't be written anywhere else if stdout fails.
+	_, _ = l.out.Write(append(b, '\n'))
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context which carries l to the functions called with it.
+func withLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// logger returns the Logger of ctx or defaultLogger.
+func logger(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return defaultLogger
+}
+
+var traceparent = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// traceID returns the trace ID of the W3C traceparent, or an empty string if it's invalid.
+// See https://www.w3.org/TR/trace-context/#traceparent-header
+func traceID(tp string) string {
+	m := traceparent.FindStringSubmatch(tp)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// SetDefaultLogger replaces the Logger used without any loggers in the context,
+// so that commands can write logs to stderr.
+func SetDefaultLogger(l *Logger) {
+	defaultLogger = l
+}
@@ -0,0 +1,109 @@
+package gcf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func decodeLogEntries(t *testing.T, buf *bytes.Buffer) []LogEntry {
+	t.Helper()
+	var entries []LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		e := LogEntry{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("%s is not JSON: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	base := NewLogger(buf).WithLabels("event_id", "1").WithTrace("nomos-sms", "4bf92f3577b34da6a3ce929d0e0e4736")
+	l := base.WithLabels("build_id", "build-id", "branch", "")
+
+	l.Infof("Sent a message to %s", "Slack")
+	base.Warningf("No labels of the build")
+
+	want := []LogEntry{
+		{
+			Severity: Info,
+			Message:  "Sent a message to Slack",
+			Labels:   map[string]string{"event_id": "1", "build_id": "build-id"},
+			Trace:    "projects/nomos-sms/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			Severity: Warning,
+			Message:  "No labels of the build",
+			Labels:   map[string]string{"event_id": "1"},
+			Trace:    "projects/nomos-sms/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+	got := decodeLogEntries(t, buf)
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Logger wrote %v, want %v, differs: (-got +want;\n%s)", got, want, diff)
+	}
+}
+
+func TestLogger_Error(t *testing.T) {
+	buf := &bytes.Buffer{}
+	NewLogger(buf).Error(errors.New("channel_not_found"), "Failed to notify with slack")
+
+	got := decodeLogEntries(t, buf)[0]
+	if got.Severity != Error || got.Type != reportedErrorEvent {
+		t.Errorf("Logger.Error() wrote severity %s and @type %s, want %s and %s", got.Severity, got.Type, Error, reportedErrorEvent)
+	}
+	if !strings.HasPrefix(got.Message, "Failed to notify with slack: channel_not_found\n") ||
+		!strings.Contains(got.Message, "TestLogger_Error") {
+		t.Errorf("Logger.Error() wrote %q, want the message with the stack", got.Message)
+	}
+}
+
+func TestEventLogger(t *testing.T) {
+	ctx := metadata.NewContext(context.Background(), &metadata.Metadata{
+		EventID:  "1",
+		Resource: &metadata.Resource{Name: "projects/nomos-sms/topics/cloud-builds"},
+	})
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		wantTrace  string
+	}{
+		{
+			"Trace of Pub/Sub",
+			map[string]string{"googclient_traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			"projects/nomos-sms/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{"Invalid traceparent", map[string]string{"traceparent": "00-xyz-01"}, ""},
+		{"No attributes", nil, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l := eventLogger(ctx, PubSubMessage{Attributes: tt.attributes})
+			if l.trace != tt.wantTrace || l.labels["event_id"] != "1" {
+				t.Errorf("eventLogger() has trace %q and labels %v, want %q and event_id 1", l.trace, l.labels, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if got := logger(context.Background()); got != defaultLogger {
+		t.Errorf("logger() = %v, want defaultLogger", got)
+	}
+	l := NewLogger(&bytes.Buffer{})
+	if got := logger(withLogger(context.Background(), l)); got != l {
+		t.Errorf("logger() = %v, want the logger of the context", got)
+	}
+}
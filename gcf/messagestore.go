@@ -0,0 +1,171 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+)
+
+// SlackMessageRef points a Slack message posted for a build.
+type SlackMessageRef struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Status  string `json:"status"`
+}
+
+// MessageStore keeps which Slack message is posted for each build.
+// Get returns nil without any errors if no messages are posted for the build.
+type MessageStore interface {
+	Get(ctx context.Context, buildID string) (*SlackMessageRef, error)
+	Put(ctx context.Context, buildID string, ref SlackMessageRef) error
+}
+
+var (
+	messageStore     MessageStore
+	onceMessageStore try.Once
+)
+
+func getMessageStore(ctx context.Context, c *SlackConfig) (MessageStore, error) {
+	err := onceMessageStore.Try(func() error {
+		var err error
+		messageStore, err = newMessageStore(ctx, c)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return messageStore, nil
+}
+
+func newMessageStore(ctx context.Context, c *SlackConfig) (MessageStore, error) {
+	switch c.MessageStore {
+	case "memory":
+		return NewMemoryMessageStore(), nil
+	case "file":
+		return NewFileMessageStore(c.MessageStorePath), nil
+	case "firestore":
+		return NewFirestoreMessageStore(ctx, c.homeProject(), c.MessageStoreCollection)
+	}
+	return nil, errors.Errorf("%s is unknown message store", c.MessageStore)
+}
+
+// MemoryMessageStore keeps messages while the instance of the function is alive.
+type MemoryMessageStore struct {
+	mu   sync.Mutex
+	refs map[string]SlackMessageRef
+}
+
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{refs: map[string]SlackMessageRef{}}
+}
+
+func (s *MemoryMessageStore) Get(ctx context.Context, buildID string) (*SlackMessageRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, ok := s.refs[buildID]
+	if !ok {
+		return nil, nil
+	}
+	return &ref, nil
+}
+
+func (s *MemoryMessageStore) Put(ctx context.Context, buildID string, ref SlackMessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs[buildID] = ref
+	return nil
+}
+
+// FileMessageStore keeps messages in a JSON file on the local file system.
+type FileMessageStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileMessageStore(path string) *FileMessageStore {
+	return &FileMessageStore{path: path}
+}
+
+func (s *FileMessageStore) load() (map[string]SlackMessageRef, error) {
+	refs := map[string]SlackMessageRef{}
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(b, &refs); err != nil {
+		return nil, errors.Wrapf(err, "Failed to decode %s", s.path)
+	}
+	return refs, nil
+}
+
+func (s *FileMessageStore) Get(ctx context.Context, buildID string) (*SlackMessageRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	ref, ok := refs[buildID]
+	if !ok {
+		return nil, nil
+	}
+	return &ref, nil
+}
+
+func (s *FileMessageStore) Put(ctx context.Context, buildID string, ref SlackMessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refs, err := s.load()
+	if err != nil {
+		return err
+	}
+	refs[buildID] = ref
+
+	b, err := json.Marshal(refs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(s.path, b, 0600))
+}
+
+// FirestoreMessageStore keeps messages as documents of a Firestore collection.
+type FirestoreMessageStore struct {
+	col *firestoreCollection
+}
+
+func NewFirestoreMessageStore(ctx context.Context, projectID, collection string) (*FirestoreMessageStore, error) {
+	col, err := newFirestoreCollection(ctx, projectID, collection)
+	if err != nil {
+		return nil, err
+	}
+	return &FirestoreMessageStore{col: col}, nil
+}
+
+func (s *FirestoreMessageStore) Get(ctx context.Context, buildID string) (*SlackMessageRef, error) {
+	fields, err := s.col.get(ctx, buildID)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return &SlackMessageRef{
+		Channel: fields["channel"],
+		TS:      fields["ts"],
+		Status:  fields["status"],
+	}, nil
+}
+
+func (s *FirestoreMessageStore) Put(ctx context.Context, buildID string, ref SlackMessageRef) error {
+	return s.col.put(ctx, buildID, map[string]string{
+		"channel": ref.Channel,
+		"ts":      ref.TS,
+		"status":  ref.Status,
+	})
+}
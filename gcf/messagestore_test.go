@@ -0,0 +1,60 @@
+package gcf
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testMessageStore(t *testing.T, s MessageStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	got, err := s.Get(ctx, "build-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("Get() returns %v for an unknown build, want nil", got)
+	}
+
+	want := SlackMessageRef{Channel: "C12345", TS: "1550000000.000100", Status: "QUEUED"}
+	if err := s.Put(ctx, "build-id", want); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Get(ctx, "build-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, &want); diff != "" {
+		t.Errorf("Get() = %v, want %v, differs: (-got +want;\n%s)", got, want, diff)
+	}
+}
+
+func TestMemoryMessageStore(t *testing.T) {
+	testMessageStore(t, NewMemoryMessageStore())
+}
+
+func TestFileMessageStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messagestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "messages.json")
+	testMessageStore(t, NewFileMessageStore(path))
+
+	// Another instance reads what the first one wrote.
+	got, err := NewFileMessageStore(path).Get(context.Background(), "build-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.TS != "1550000000.000100" {
+		t.Errorf("Get() = %v, want the message written by another store", got)
+	}
+}
@@ -0,0 +1,176 @@
+package gcf
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMessageText is the text of messages unless templates are given.
+const DefaultMessageText = `{{.Service}} was built as {{.ID}}`
+
+// DefaultMessageTemplate is the key of messages in the config file for statuses without their own.
+const DefaultMessageTemplate = "default"
+
+// MessageTemplate defines the message of builds with a status.
+type MessageTemplate struct {
+	// Text is a text/template with MessageParams for the notification and the header.
+	// It is DefaultMessageText if empty.
+	Text string `yaml:"text"`
+	// Fields replace the built-in fields if given. Fields rendered as empty are omitted.
+	Fields []*MessageField `yaml:"fields"`
+
+	tmpl *template.Template
+}
+
+// MessageField is a field of a message whose value is a text/template with MessageParams.
+type MessageField struct {
+	Title string `yaml:"title"`
+	Value string `yaml:"value"`
+
+	tmpl *template.Template
+}
+
+// MessageParams can be used in templates of messages.
+// Methods of BuildEvent such as {{.Branch.ToVersion}} and {{.AppURLs .Config}} can be called.
+type MessageParams struct {
+	BuildEvent
+	Service string
+	Config  *SlackConfig
+}
+
+// newMessageParams gives empty substitutions to builds without them such as manual ones,
+// so that templates like {{.Substitutions.Custom._DEPLOY_ENV}} work for any builds.
+func newMessageParams(b BuildEvent, c *SlackConfig) MessageParams {
+	if b.Substitutions == nil {
+		b.Substitutions = &BuildSubstitutions{}
+	}
+	return MessageParams{BuildEvent: b, Service: Service, Config: c}
+}
+
+// messageFuncs can be used in templates of messages in addition to the builtin functions.
+var messageFuncs = template.FuncMap{
+	// duration formats durations such as {{duration .RunDuration}} like the built-in message.
+	"duration": formatDuration,
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// defaultMessageTemplates are used in each language unless messages are given in the config file.
+var defaultMessageTemplates = map[string]*MessageTemplate{}
+
+func init() {
+	for _, lang := range []string{English, Japanese} {
+		m := &MessageTemplate{Text: translate(lang, DefaultMessageText)}
+		if err := m.compile(DefaultMessageTemplate); err != nil {
+			panic(err)
+		}
+		defaultMessageTemplates[lang] = m
+	}
+}
+
+// sampleMessageParams tries templates at cold start, so that mistakes such as unknown fields are found
+// before builds are notified.
+func sampleMessageParams(status string) MessageParams {
+	start := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	return MessageParams{
+		BuildEvent: BuildEvent{
+			ID:         "5d8f0a3e-7b21-4c4e-9a6f-2e1d3c4b5a69",
+			ProjectID:  "project",
+			Status:     status,
+			Source:     &BuildSource{RepoSource: &BuildRepoSource{RepoName: "repo", BranchName: "master"}},
+			CreateTime: start,
+			StartTime:  start.Add(time.Second),
+			FinishTime: start.Add(time.Minute),
+			LogURL:     "https://console.cloud.google.com/cloud-build/builds/5d8f0a3e-7b21-4c4e-9a6f-2e1d3c4b5a69",
+			Tags:       &BuildTags{TagDeployDefault},
+			Substitutions: &BuildSubstitutions{
+				BranchName: "master",
+				CommitSHA:  "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+				RepoName:   "repo",
+				Custom:     map[string]string{},
+			},
+			Streak: &BuildStreak{},
+		},
+		Service: Service,
+		Config:  &SlackConfig{ProjectID: "project"},
+	}
+}
+
+// compile parses the templates and tries them once to find mistakes at cold start.
+// Missing keys of maps such as {{.Substitutions.Custom._DEPLOY_ENV}} are empty, because builds have different keys.
+func (m *MessageTemplate) compile(status string) error {
+	text := m.Text
+	if text == "" {
+		text = DefaultMessageText
+	}
+
+	tmpl, err := template.New(status).Funcs(messageFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to parse the message text of %s", status)
+	}
+	m.tmpl = tmpl
+	for _, f := range m.Fields {
+		tmpl, err := template.New(f.Title).Funcs(messageFuncs).Option("missingkey=zero").Parse(f.Value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to parse the field %s of %s", f.Title, status)
+		}
+		f.tmpl = tmpl
+	}
+
+	p := sampleMessageParams(status)
+	if _, err := m.renderText(p); err != nil {
+		return errors.Wrapf(err, "Failed to render the message text of %s", status)
+	}
+	_, err = m.renderFields(p)
+	return errors.Wrapf(err, "Failed to render fields of %s", status)
+}
+
+func (m *MessageTemplate) renderText(p MessageParams) (string, error) {
+	return renderMessageTemplate(m.tmpl, p)
+}
+
+// renderFields returns nil if the template has no fields, so that the built-in fields are used.
+func (m *MessageTemplate) renderFields(p MessageParams) ([]SlackText, error) {
+	var fields []SlackText
+	for _, f := range m.Fields {
+		v, err := renderMessageTemplate(f.tmpl, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to render the field %s", f.Title)
+		}
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		fields = append(fields, field(f.Title, v))
+	}
+	return fields, nil
+}
+
+func renderMessageTemplate(tmpl *template.Template, p MessageParams) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, p); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return sb.String(), nil
+}
+
+// messageTemplate returns the template of the status in the config file, or the default of the language.
+func (c *SlackConfig) messageTemplate(status string) *MessageTemplate {
+	if m, ok := c.File.Messages[status]; ok {
+		return m
+	}
+	if m, ok := c.File.Messages[DefaultMessageTemplate]; ok {
+		return m
+	}
+	return c.defaultMessageTemplate()
+}
+
+func (c *SlackConfig) defaultMessageTemplate() *MessageTemplate {
+	if m, ok := defaultMessageTemplates[c.language()]; ok {
+		return m
+	}
+	return defaultMessageTemplates[English]
+}
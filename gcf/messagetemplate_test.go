@@ -0,0 +1,136 @@
+package gcf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessageTemplate_compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    *MessageTemplate
+		wantErr bool
+	}{
+		{"Default", &MessageTemplate{}, false},
+		{"Helpers", &MessageTemplate{Text: "{{.Branch.ToVersion}} {{duration .RunDuration}} {{range .AppURLs .Config}}{{.URL}}{{end}}"}, false},
+		{"Fields", &MessageTemplate{Fields: []*MessageField{{Title: "Commit", Value: "{{.ShortSHA}}"}}}, false},
+		{"Custom substitution", &MessageTemplate{Text: "{{.Substitutions.Custom._DEPLOY_ENV}}"}, false},
+		{"Broken text", &MessageTemplate{Text: "{{.ID"}, true},
+		{"Unknown field", &MessageTemplate{Text: "{{.Branches}}"}, true},
+		{"Unknown function", &MessageTemplate{Text: "{{short .ID}}"}, true},
+		{"Broken field", &MessageTemplate{Fields: []*MessageField{{Title: "Commit", Value: "{{.SHA}}"}}}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.tmpl.compile("FAILURE"); (err != nil) != tt.wantErr {
+				t.Errorf("MessageTemplate.compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderSlackMessage_withTemplates(t *testing.T) {
+	f, err := LoadFileConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &SlackConfig{ProjectID: "nomos-sms", File: *f}
+	start := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		status     string
+		tags       *BuildTags
+		sub        *BuildSubstitutions
+		wantText   string
+		wantFields []SlackText
+	}{
+		{
+			"SUCCESS",
+			"SUCCESS",
+			&BuildTags{"deploy-api-service"},
+			&BuildSubstitutions{BranchName: "feature/docs"},
+			"Nomos feature-docs is ready https://feature-docs-dot-api-dot-nomos-sms.appspot.com " +
+				"https://feature-docs-dot-api-dot-nomos-sms.appspot.com/docs",
+			nil,
+		},
+		{
+			"FAILURE",
+			"FAILURE",
+			nil,
+			&BuildSubstitutions{BranchName: "feature/docs", CommitSHA: "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c"},
+			":fire: feature/docs failed after 1m30s",
+			[]SlackText{field("Commit", "9f3b1e2")},
+		},
+		{
+			"Custom substitution",
+			"CANCELLED",
+			nil,
+			&BuildSubstitutions{BranchName: "feature/docs", Custom: map[string]string{"_DEPLOY_ENV": "staging"}},
+			"Nomos to staging was cancelled",
+			nil,
+		},
+		{
+			"Missing custom substitution",
+			"CANCELLED",
+			nil,
+			&BuildSubstitutions{BranchName: "feature/docs"},
+			"Nomos was cancelled",
+			nil,
+		},
+		{
+			"Blank text falls back to the default",
+			"QUEUED",
+			nil,
+			&BuildSubstitutions{BranchName: "feature/docs"},
+			"Nomos was built as build-id",
+			nil,
+		},
+		{
+			"Build without substitutions",
+			"CANCELLED",
+			nil,
+			nil,
+			"Nomos was cancelled",
+			nil,
+		},
+		{
+			"Default for the other statuses",
+			"TIMEOUT",
+			nil,
+			&BuildSubstitutions{BranchName: "feature/docs"},
+			"Nomos was built as build-id",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				ID:            "build-id",
+				ProjectID:     "nomos-sms",
+				Status:        tt.status,
+				StartTime:     start,
+				FinishTime:    start.Add(90 * time.Second),
+				Tags:          tt.tags,
+				Substitutions: tt.sub,
+			}
+			got := RenderSlackMessage(e, c)
+			if got.Text != tt.wantText || got.Attachments[0].Blocks[0].Text.Text != tt.wantText {
+				t.Errorf("RenderSlackMessage().Text = %v, want %v", got.Text, tt.wantText)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+			fields := got.Attachments[0].Blocks[1].Fields
+			if diff := cmp.Diff(fields, tt.wantFields); diff != "" {
+				t.Errorf("RenderSlackMessage() fields = %v, want %v, differs: (-got +want;\n%s)", fields, tt.wantFields, diff)
+			}
+		})
+	}
+}
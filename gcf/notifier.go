@@ -0,0 +1,79 @@
+package gcf
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Notifier sends a result of Cloud Build to a destination.
+type Notifier interface {
+	Notify(ctx context.Context, b BuildEvent, c *SlackConfig) error
+}
+
+// NotifierFunc is an adapter to use an ordinary function as a Notifier.
+type NotifierFunc func(ctx context.Context, b BuildEvent, c *SlackConfig) error
+
+func (f NotifierFunc) Notify(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+	return f(ctx, b, c)
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = map[string]Notifier{
+		"slack": &SlackNotifier{},
+	}
+)
+
+// RegisterNotifier makes a Notifier available by the name in NOTIFIERS.
+// A Notifier registered with the same name is replaced.
+func RegisterNotifier(name string, n Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers[name] = n
+}
+
+func findNotifier(name string) (Notifier, bool) {
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+	n, ok := notifiers[name]
+	return n, ok
+}
+
+// notify sends b to every Notifier chosen in c.
+// It tries all of them even if some fail, and the failure is permanent only if all the failures are.
+func notify(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+	if len(c.Notifiers) == 0 {
+		return Permanent(errors.New("No notifiers are configured"))
+	}
+
+	ns := make([]Notifier, len(c.Notifiers))
+	for i, name := range c.Notifiers {
+		n, ok := findNotifier(name)
+		if !ok {
+			return Permanent(errors.Errorf("%s is unknown notifier", name))
+		}
+		ns[i] = n
+	}
+
+	var failed []string
+	permanent := true
+	for i, n := range ns {
+		if err := n.Notify(ctx, b, c); err != nil {
+			logger(ctx).Error(err, "Failed to notify with "+c.Notifiers[i])
+			failed = append(failed, c.Notifiers[i])
+			permanent = permanent && IsPermanent(err)
+		}
+	}
+	if len(failed) > 0 {
+		err := errors.Errorf("Failed to notify with %s", strings.Join(failed, ", "))
+		if permanent {
+			return Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
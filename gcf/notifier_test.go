@@ -0,0 +1,52 @@
+package gcf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNotify(t *testing.T) {
+	var called []string
+	record := func(name string, err error) Notifier {
+		return NotifierFunc(func(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+			called = append(called, name+":"+b.ID)
+			return err
+		})
+	}
+	RegisterNotifier("test-ok", record("test-ok", nil))
+	RegisterNotifier("test-ng", record("test-ng", errors.New("failed")))
+
+	tests := []struct {
+		name      string
+		notifiers []string
+		want      []string
+		wantErr   bool
+	}{
+		{"Send to a notifier", []string{"test-ok"}, []string{"test-ok:build-id"}, false},
+		{
+			"Send to all notifiers even if one fails",
+			[]string{"test-ng", "test-ok"},
+			[]string{"test-ng:build-id", "test-ok:build-id"},
+			true,
+		},
+		{"Unknown notifier", []string{"test-ok", "unknown"}, nil, true},
+		{"No notifiers", nil, nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			called = nil
+			c := &SlackConfig{Notifiers: tt.notifiers}
+			err := notify(context.Background(), BuildEvent{ID: "build-id"}, c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(called, tt.want); diff != "" {
+				t.Errorf("notify() called %v, want %v, differs: (-got +want;\n%s)", called, tt.want, diff)
+			}
+		})
+	}
+}
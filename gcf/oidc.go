@@ -0,0 +1,231 @@
+package gcf
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GoogleCertsURL is the JSON Web Key Set which Google signs ID tokens with.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// googleIssuers are issuers of ID tokens of Google.
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// KeySet finds a public key to verify tokens by its key ID.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeySet is a fixed KeySet such as keys generated locally.
+type StaticKeySet map[string]*rsa.PublicKey
+
+func (s StaticKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k, ok := s[kid]
+	if !ok {
+		return nil, errors.Errorf("%s is unknown key", kid)
+	}
+	return k, nil
+}
+
+// RemoteKeySet fetches a JSON Web Key Set from URL and caches it for MaxAge.
+// It fetches again on an unknown key ID, because keys are rotated.
+type RemoteKeySet struct {
+	URL        string
+	HTTPClient *http.Client
+	MaxAge     time.Duration
+
+	mu        sync.Mutex
+	keys      StaticKeySet
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{URL: url, HTTPClient: &http.Client{Timeout: 10 * time.Second}, MaxAge: time.Hour}
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[kid]
+	if ok && time.Since(s.fetchedAt) < s.MaxAge {
+		return k, nil
+	}
+	// Forged key IDs don't make it fetch keys too often.
+	if !ok && s.keys != nil && time.Since(s.fetchedAt) < time.Minute {
+		return nil, errors.Errorf("%s is unknown key", kid)
+	}
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+	return s.keys.Key(ctx, kid)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	res, err := s.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to fetch keys from %s", s.URL)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("%s responded %d", s.URL, res.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return errors.Wrapf(err, "Failed to decode keys of %s", s.URL)
+	}
+
+	keys := StaticKeySet{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return errors.Wrapf(err, "Invalid key %s of %s", k.Kid, s.URL)
+		}
+		keys[k.Kid] = pub
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// IDTokenClaims are claims of an ID token which Google issues for a service account.
+type IDTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Audience      string `json:"aud"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	IssuedAt      int64  `json:"iat"`
+	Expiry        int64  `json:"exp"`
+}
+
+// OIDCVerifier verifies ID tokens of Google which Pub/Sub attaches to push requests.
+// See https://cloud.google.com/pubsub/docs/authenticate-push-subscriptions
+type OIDCVerifier struct {
+	Audience string
+	// Email is the service account of the push subscription, which is required,
+	// because any accounts of Google can get tokens for the audience.
+	Email string
+	Keys  KeySet
+	// Leeway allows clocks of Google and this server to differ.
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+func NewOIDCVerifier(audience, email string, keys KeySet) *OIDCVerifier {
+	return &OIDCVerifier{Audience: audience, Email: email, Keys: keys, Leeway: time.Minute, now: time.Now}
+}
+
+// Verify checks the signature, the issuer, the audience, the expiry and the email of the token.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (*IDTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Token is not JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode the header of the token")
+	}
+	if header.Alg != "RS256" {
+		return nil, errors.Errorf("%s is unsupported algorithm", header.Alg)
+	}
+
+	key, err := v.Keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to find the key of the token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode the signature of the token")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.Wrap(err, "Invalid signature of the token")
+	}
+
+	claims := &IDTokenClaims{}
+	if err := decodeJWTPart(parts[1], claims); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode claims of the token")
+	}
+	return claims, v.verifyClaims(claims)
+}
+
+func (v *OIDCVerifier) verifyClaims(c *IDTokenClaims) error {
+	issued := false
+	for _, iss := range googleIssuers {
+		issued = issued || c.Issuer == iss
+	}
+	if !issued {
+		return errors.Errorf("%s is not an issuer of Google", c.Issuer)
+	}
+	if c.Audience != v.Audience {
+		return errors.Errorf("%s is not the audience %s", c.Audience, v.Audience)
+	}
+	now := v.now()
+	if now.After(time.Unix(c.Expiry, 0).Add(v.Leeway)) {
+		return errors.Errorf("Token expired at %s", time.Unix(c.Expiry, 0))
+	}
+	if now.Before(time.Unix(c.IssuedAt, 0).Add(-v.Leeway)) {
+		return errors.Errorf("Token is issued in the future at %s", time.Unix(c.IssuedAt, 0))
+	}
+	if v.Email == "" {
+		return errors.New("No service account to verify the token")
+	}
+	if c.Email != v.Email || !c.EmailVerified {
+		return errors.Errorf("%s is not the service account %s", c.Email, v.Email)
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(b, v))
+}
@@ -0,0 +1,133 @@
+package gcf
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSigningKey = func() *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}()
+
+// signTestToken signs claims with testSigningKey as the key kid.
+func signTestToken(t *testing.T, kid string, claims interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, testSigningKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCVerifier_Verify(t *testing.T) {
+	now := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	v := NewOIDCVerifier("https://notify-slack.example.com/", "push@nomos-sms.iam.gserviceaccount.com",
+		StaticKeySet{"key-1": &testSigningKey.PublicKey})
+	v.now = func() time.Time { return now }
+
+	valid := func() IDTokenClaims {
+		return IDTokenClaims{
+			Issuer:        "https://accounts.google.com",
+			Audience:      "https://notify-slack.example.com/",
+			Email:         "push@nomos-sms.iam.gserviceaccount.com",
+			EmailVerified: true,
+			IssuedAt:      now.Add(-time.Minute).Unix(),
+			Expiry:        now.Add(time.Hour).Unix(),
+		}
+	}
+	with := func(f func(c *IDTokenClaims)) IDTokenClaims {
+		c := valid()
+		f(&c)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"Valid token", signTestToken(t, "key-1", valid()), false},
+		{"Unknown key", signTestToken(t, "key-2", valid()), true},
+		{"Other audience", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.Audience = "https://example.com/" })), true},
+		{"Other issuer", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.Issuer = "https://example.com" })), true},
+		{"Other account", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.Email = "other@example.com" })), true},
+		{"Unverified email", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.EmailVerified = false })), true},
+		{"No email", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.Email, c.EmailVerified = "", false })), true},
+		{"Expired", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.Expiry = now.Add(-time.Hour).Unix() })), true},
+		{"Issued in the future", signTestToken(t, "key-1", with(func(c *IDTokenClaims) { c.IssuedAt = now.Add(time.Hour).Unix() })), true},
+		{"Tampered", signTestToken(t, "key-1", valid()) + "x", true},
+		{"Not JWT", "token", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := v.Verify(context.Background(), tt.token); (err != nil) != tt.wantErr {
+				t.Errorf("OIDCVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	anyone := NewOIDCVerifier("https://notify-slack.example.com/", "", StaticKeySet{"key-1": &testSigningKey.PublicKey})
+	anyone.now = v.now
+	if _, err := anyone.Verify(context.Background(), signTestToken(t, "key-1", valid())); err == nil {
+		t.Errorf("OIDCVerifier.Verify() returns no errors without the service account")
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	var fetched int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		pub := testSigningKey.PublicKey
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []jsonWebKey{{
+				Kid: "key-1",
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	s := NewRemoteKeySet(srv.URL)
+	for i := 0; i < 2; i++ {
+		k, err := s.Key(context.Background(), "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.N.Cmp(testSigningKey.N) != 0 || k.E != testSigningKey.E {
+			t.Errorf("RemoteKeySet.Key() = %v, want the public key of the server", k)
+		}
+	}
+	if _, err := s.Key(context.Background(), "key-2"); err == nil {
+		t.Error("RemoteKeySet.Key() of an unknown key error = nil, want an error")
+	}
+	if fetched != 1 {
+		t.Errorf("RemoteKeySet fetched keys %d times, want 1", fetched)
+	}
+}
@@ -0,0 +1,108 @@
+package gcf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProjectConfig has settings of a project whose cloud-builds topic is watched in addition to GCP_PROJECT.
+// Empty settings are the same as the ones of GCP_PROJECT.
+type ProjectConfig struct {
+	ID string `yaml:"id"`
+	// Domain of apps deployed by builds, which is <id>.appspot.com if empty.
+	Domain string `yaml:"domain"`
+	// Channel and Webhook are the default destination of builds of the project.
+	Channel string                  `yaml:"channel"`
+	Webhook string                  `yaml:"webhook"`
+	Deploys map[string][]*DeployURL `yaml:"deploys"`
+	Routes  []Route                 `yaml:"routes"`
+}
+
+// validate compiles templates of the project and names its routes like the ones of the config file.
+func (p *ProjectConfig) validate() error {
+	if p.ID == "" {
+		return errors.New("project has no id")
+	}
+	for i := range p.Routes {
+		if p.Routes[i].Name == "" {
+			p.Routes[i].Name = fmt.Sprintf("%s#%d", p.ID, i+1)
+		}
+		if err := p.Routes[i].validate(); err != nil {
+			return errors.Wrapf(err, "Invalid project %s", p.ID)
+		}
+	}
+	for tag, us := range p.Deploys {
+		for _, u := range us {
+			if err := u.compile(tag); err != nil {
+				return errors.Wrapf(err, "Invalid project %s", p.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *FileConfig) project(id string) (*ProjectConfig, bool) {
+	for i := range c.Projects {
+		if c.Projects[i].ID == id {
+			return &c.Projects[i], true
+		}
+	}
+	return nil, false
+}
+
+// watchedProject returns the project of the cloud-builds topic.
+// It returns an empty project for an empty resource such as of push requests without PUSH_TOPIC,
+// and false for other topics.
+func watchedProject(resource string) (string, bool) {
+	if resource == "" {
+		return "", true
+	}
+	ps := strings.Split(resource, "/")
+	if len(ps) != 4 || ps[0] != "projects" || ps[2] != "topics" || ps[3] != "cloud-builds" {
+		return "", false
+	}
+	return ps[1], true
+}
+
+// ForProject returns a copy of c with the settings of the project,
+// or false if the project is neither GCP_PROJECT nor in the config file.
+func (c *SlackConfig) ForProject(id string) (*SlackConfig, bool) {
+	p, ok := c.File.project(id)
+	if !ok {
+		if id != c.ProjectID {
+			return nil, false
+		}
+		copied := *c
+		return &copied, true
+	}
+
+	pc := *c
+	pc.homeProjectID = c.homeProject()
+	pc.ProjectID = p.ID
+	pc.domain = p.Domain
+	if p.Channel != "" {
+		pc.SlackChannel = p.Channel
+	}
+	if p.Webhook != "" {
+		pc.SlackWebhook = p.Webhook
+		pc.forgetSecret("SLACK_WEBHOOK")
+	}
+	if p.Deploys != nil {
+		pc.File.Deploys = p.Deploys
+	}
+	if p.Routes != nil {
+		pc.File.Routes = p.Routes
+	}
+	return &pc, true
+}
+
+// homeProject returns GCP_PROJECT even for configs of other projects,
+// because stores and secrets of the function are shared by all of them.
+func (c *SlackConfig) homeProject() string {
+	if c.homeProjectID != "" {
+		return c.homeProjectID
+	}
+	return c.ProjectID
+}
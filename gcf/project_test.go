@@ -0,0 +1,90 @@
+package gcf
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWatchedProject(t *testing.T) {
+	tests := []struct {
+		resource string
+		want     string
+		wantOK   bool
+	}{
+		{"projects/nomos-sms/topics/cloud-builds", "nomos-sms", true},
+		{"projects/nomos-staging/topics/cloud-builds", "nomos-staging", true},
+		{"", "", true},
+		{"projects/nomos-sms/topics/backup-firestore", "", false},
+		{"projects/nomos-sms/subscriptions/cloud-builds", "", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.resource, func(t *testing.T) {
+			t.Parallel()
+			got, ok := watchedProject(tt.resource)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("watchedProject() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSlackConfig_ForProject(t *testing.T) {
+	f, err := LoadFileConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &SlackConfig{ProjectID: "nomos-sms", SlackChannel: "#ci", File: *f}
+	e := BuildEvent{
+		Source: &BuildSource{RepoSource: &BuildRepoSource{BranchName: "feature/docs"}},
+		Tags:   &BuildTags{"deploy-default-service"},
+	}
+
+	tests := []struct {
+		name        string
+		project     string
+		wantOK      bool
+		wantChannel string
+		wantURLs    []AppURL
+		wantRoute   string
+	}{
+		{"GCP_PROJECT", "nomos-sms", true, "#ci", nil, "dependabot (drop)"},
+		{
+			"Watched project", "nomos-staging", true, "#ci-staging",
+			[]AppURL{{Title: "Staging URL", URL: "https://feature-docs-dot-staging.example.com"}},
+			"nomos-staging#1 (drop)",
+		},
+		{"Unknown project", "nomos-dev", false, "", nil, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := c.ForProject(tt.project)
+			if ok != tt.wantOK {
+				t.Fatalf("SlackConfig.ForProject() = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.ProjectID != tt.project || got.SlackChannel != tt.wantChannel {
+				t.Errorf("SlackConfig.ForProject() = %s in %s, want %s in %s", got.ProjectID, got.SlackChannel, tt.project, tt.wantChannel)
+			}
+			if home := got.homeProject(); home != "nomos-sms" {
+				t.Errorf("SlackConfig.homeProject() = %v, want %v", home, "nomos-sms")
+			}
+			urls := e.AppURLs(got)
+			if diff := cmp.Diff(urls, tt.wantURLs); diff != "" {
+				t.Errorf("BuildEvent.AppURLs() = %v, want %v, differs: (-got +want;\n%s)", urls, tt.wantURLs, diff)
+			}
+			dependabot := BuildEvent{Substitutions: &BuildSubstitutions{BranchName: "dependabot/npm"}}
+			if r := got.File.MatchRoute(dependabot); r == nil || r.String() != tt.wantRoute {
+				t.Errorf("FileConfig.MatchRoute() = %v, want %v", r, tt.wantRoute)
+			}
+		})
+	}
+	if c.ProjectID != "nomos-sms" || c.SlackChannel != "#ci" {
+		t.Errorf("SlackConfig.ForProject() changes the original config")
+	}
+}
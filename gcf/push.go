@@ -0,0 +1,94 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+// maxPushBody is larger than the limit of a message of Pub/Sub.
+const maxPushBody = 11 << 20
+
+type PushConfig struct {
+	// Audience of OIDC tokens, which is the URL of the endpoint unless the subscription specifies it.
+	Audience string `envconfig:"push_audience"`
+	// ServiceAccount is the email of the service account of the subscription, which is required unless SkipAuth.
+	ServiceAccount string `envconfig:"push_service_account"`
+	// Topic is the resource name of the subscribed topic, because push requests don't tell it.
+	Topic string `envconfig:"push_topic"`
+	// SkipAuth accepts requests without tokens such as ones from the emulator of Pub/Sub.
+	SkipAuth bool `envconfig:"push_skip_auth"`
+}
+
+// PushHandler receives messages of a push subscription of Pub/Sub and handles them
+// in the same way as background functions.
+// Success and permanent failures are acknowledged with 204, and other failures are retried with 500.
+type PushHandler struct {
+	Topic  string
+	Handle func(ctx context.Context, m PubSubMessage) error
+	// Verifier is nil not to authenticate requests.
+	Verifier *OIDCVerifier
+}
+
+// NewNotifySlackHandler returns PushHandler of NotifySlack configured with environment variables.
+func NewNotifySlackHandler() (*PushHandler, error) {
+	sc, err := getSlackConfig(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get config about Slack")
+	}
+	pc := &PushConfig{}
+	if err := envconfig.Process("", pc); err != nil {
+		return nil, errors.Wrap(err, "Failed to get config about push")
+	}
+
+	h := &PushHandler{Topic: pc.Topic, Handle: NotifySlack}
+	// Builds of several projects are told apart by their project IDs.
+	if h.Topic == "" && len(sc.File.Projects) == 0 {
+		h.Topic = sc.WatchingResource()
+	}
+	if !pc.SkipAuth {
+		if pc.Audience == "" {
+			return nil, errors.New("PUSH_AUDIENCE is required to authenticate requests")
+		}
+		if pc.ServiceAccount == "" {
+			return nil, errors.New("PUSH_SERVICE_ACCOUNT is required to authenticate requests")
+		}
+		h.Verifier = NewOIDCVerifier(pc.Audience, pc.ServiceAccount, NewRemoteKeySet(GoogleCertsURL))
+	}
+	return h, nil
+}
+
+func (h *PushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.Verifier != nil {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, err := h.Verifier.Verify(r.Context(), token); err != nil {
+			defaultLogger.Warningf("Unauthenticated push request: %v", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// The envelope of a push request is the same as the data of CloudEvents.
+	d := MessagePublishedData{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBody)).Decode(&d); err != nil {
+		defaultLogger.Warningf("Invalid push request: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ctx := pubsubContext(r.Context(), d.Message.MessageID, d.Message.PublishTime, h.Topic)
+	if err := h.Handle(ctx, d.Message); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package gcf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestPushHandler(t *testing.T) {
+	now := time.Now()
+	verifier := NewOIDCVerifier("https://notify-slack.example.com/", "push@nomos-sms.iam.gserviceaccount.com",
+		StaticKeySet{"key-1": &testSigningKey.PublicKey})
+	token := signTestToken(t, "key-1", IDTokenClaims{
+		Issuer:        "accounts.google.com",
+		Audience:      "https://notify-slack.example.com/",
+		Email:         "push@nomos-sms.iam.gserviceaccount.com",
+		EmailVerified: true,
+		IssuedAt:      now.Unix(),
+		Expiry:        now.Add(time.Hour).Unix(),
+	})
+	body := `{
+  "message": {
+    "attributes": {"buildId": "build-id"},
+    "data": "eyJpZCI6ImJ1aWxkLWlkIn0=",
+    "messageId": "123",
+    "publishTime": "2019-02-14T01:00:00Z"
+  },
+  "subscription": "projects/nomos-sms/subscriptions/notify-slack"
+}`
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		body       string
+		handleErr  error
+		wantStatus int
+		wantCalled bool
+	}{
+		{"Handled", http.MethodPost, token, body, nil, http.StatusNoContent, true},
+		{"Retried", http.MethodPost, token, body, errors.New("failed"), http.StatusInternalServerError, true},
+		{"No token", http.MethodPost, "", body, nil, http.StatusUnauthorized, false},
+		{"Invalid token", http.MethodPost, token + "x", body, nil, http.StatusUnauthorized, false},
+		{"Broken body", http.MethodPost, token, "{", nil, http.StatusBadRequest, false},
+		{"GET", http.MethodGet, token, "", nil, http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var called bool
+			h := &PushHandler{
+				Topic:    "projects/nomos-sms/topics/cloud-builds",
+				Verifier: verifier,
+				Handle: func(ctx context.Context, m PubSubMessage) error {
+					called = true
+					meta, err := metadata.FromContext(ctx)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want := &metadata.Metadata{
+						EventID:   "123",
+						Timestamp: time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC),
+						EventType: "google.pubsub.topic.publish",
+						Resource: &metadata.Resource{
+							Service: "pubsub.googleapis.com",
+							Name:    "projects/nomos-sms/topics/cloud-builds",
+							Type:    "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+						},
+					}
+					if diff := cmp.Diff(meta, want); diff != "" {
+						t.Errorf("metadata = %v, want %v, differs: (-got +want;\n%s)", meta, want, diff)
+					}
+					if m.Data != "eyJpZCI6ImJ1aWxkLWlkIn0=" || m.Attributes["buildId"] != "build-id" {
+						t.Errorf("message = %v, want the message of the request", m)
+					}
+					return tt.handleErr
+				},
+			}
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus || called != tt.wantCalled {
+				t.Errorf("PushHandler responded %d and called %v, want %d and %v", rec.Code, called, tt.wantStatus, tt.wantCalled)
+			}
+		})
+	}
+}
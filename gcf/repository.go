@@ -0,0 +1,175 @@
+package gcf
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RepositoryHost is a hosting service of repositories, which decides shapes of URLs.
+type RepositoryHost string
+
+const (
+	GitHub                  RepositoryHost = "github"
+	GitLab                  RepositoryHost = "gitlab"
+	Bitbucket               RepositoryHost = "bitbucket"
+	CloudSourceRepositories RepositoryHost = "csr"
+)
+
+// Repository is a repository of source code which a build comes from.
+type Repository struct {
+	// URL is the top page of the repository such as https://github.com/bm-sms/nomos.
+	URL string `yaml:"url"`
+	// Host is detected from URL if empty.
+	Host RepositoryHost `yaml:"host"`
+}
+
+// NewRepository returns a Repository of url with the host detected from its domain.
+func NewRepository(rawurl string) Repository {
+	return Repository{URL: strings.TrimSuffix(rawurl, "/"), Host: detectRepositoryHost(rawurl)}
+}
+
+func detectRepositoryHost(rawurl string) RepositoryHost {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return GitHub
+	}
+	switch {
+	case strings.Contains(u.Host, "gitlab"):
+		return GitLab
+	case strings.Contains(u.Host, "bitbucket"):
+		return Bitbucket
+	case u.Host == "source.cloud.google.com":
+		return CloudSourceRepositories
+	}
+	return GitHub
+}
+
+func (r Repository) host() RepositoryHost {
+	if r.Host != "" {
+		return r.Host
+	}
+	return detectRepositoryHost(r.URL)
+}
+
+func (r Repository) BranchURL(b RepositoryBranch) string {
+	switch r.host() {
+	case GitLab:
+		return fmt.Sprintf("%s/-/tree/%s", r.URL, b)
+	case Bitbucket:
+		return fmt.Sprintf("%s/branch/%s", r.URL, b)
+	case CloudSourceRepositories:
+		return fmt.Sprintf("%s/+/%s", r.URL, b)
+	}
+	return fmt.Sprintf("%s/tree/%s", r.URL, b)
+}
+
+func (r Repository) CommitURL(sha string) string {
+	switch r.host() {
+	case GitLab:
+		return fmt.Sprintf("%s/-/commit/%s", r.URL, sha)
+	case Bitbucket:
+		return fmt.Sprintf("%s/commits/%s", r.URL, sha)
+	case CloudSourceRepositories:
+		return fmt.Sprintf("%s/+/%s", r.URL, sha)
+	}
+	return fmt.Sprintf("%s/commit/%s", r.URL, sha)
+}
+
+// PullRequestURL returns an empty string for Cloud Source Repositories which has no pull requests.
+func (r Repository) PullRequestURL(number string) string {
+	switch r.host() {
+	case GitLab:
+		return fmt.Sprintf("%s/-/merge_requests/%s", r.URL, number)
+	case Bitbucket:
+		return fmt.Sprintf("%s/pull-requests/%s", r.URL, number)
+	case CloudSourceRepositories:
+		return ""
+	}
+	return fmt.Sprintf("%s/pull/%s", r.URL, number)
+}
+
+// Repository resolves where the build comes from.
+// Repositories in the config file are looked up by the connected repository, the link of Developer Connect,
+// REPO_NAME and the repository of Cloud Source Repositories in order.
+// Without them, REPO_NAME is regarded as a repository of the same owner as REPOSITORY_URL,
+// the name of Cloud Source Repositories tells a mirrored repository of GitHub or Bitbucket,
+// and the URL of a Git source is the repository itself.
+func (e BuildEvent) Repository(c *SlackConfig) Repository {
+	var names []string
+	if e.Source != nil && e.Source.ConnectedRepository != nil {
+		n := e.Source.ConnectedRepository.Repository
+		names = append(names, n, path.Base(n))
+	}
+	if e.Source != nil && e.Source.DeveloperConnect != nil {
+		n := e.Source.DeveloperConnect.GitRepositoryLink
+		names = append(names, n, path.Base(n))
+	}
+	if e.Substitutions != nil && e.Substitutions.RepoName != "" {
+		names = append(names, e.Substitutions.RepoName)
+	}
+	if e.Source != nil && e.Source.RepoSource != nil && e.Source.RepoSource.RepoName != "" {
+		names = append(names, e.Source.RepoSource.RepoName)
+	}
+	for _, n := range names {
+		if r, ok := c.File.Repositories[n]; ok {
+			r.URL = strings.TrimSuffix(r.URL, "/")
+			return r
+		}
+	}
+
+	def := NewRepository(c.repositoryURL())
+	if owner, ok := ownerURL(def.URL); ok && e.Substitutions != nil && e.Substitutions.RepoName != "" {
+		return Repository{URL: owner + "/" + e.Substitutions.RepoName, Host: def.Host}
+	}
+	if e.Source != nil && e.Source.RepoSource != nil && e.Source.RepoSource.RepoName != "" {
+		return e.Source.RepoSource.repository(e.ProjectID)
+	}
+	if e.Source != nil && e.Source.GitSource != nil && strings.HasPrefix(e.Source.GitSource.URL, "https://") {
+		return NewRepository(strings.TrimSuffix(e.Source.GitSource.URL, ".git"))
+	}
+	return def
+}
+
+// ownerURL returns the URL of the owner of the repository such as https://github.com/bm-sms,
+// or false if the URL has no owner.
+func ownerURL(rawurl string) (string, bool) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	ps := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(ps) < 2 || ps[0] == "" {
+		return "", false
+	}
+	u.Path = "/" + strings.Join(ps[:len(ps)-1], "/")
+	return u.String(), true
+}
+
+// repository of Cloud Source Repositories, which can be mirrored from GitHub or Bitbucket
+// with names like "github_<owner>_<repo>".
+func (s *BuildRepoSource) repository(projectID string) Repository {
+	prefixes := map[string]Repository{
+		"github_":    {URL: "https://github.com", Host: GitHub},
+		"bitbucket_": {URL: "https://bitbucket.org", Host: Bitbucket},
+	}
+	for p, r := range prefixes {
+		if !strings.HasPrefix(s.RepoName, p) {
+			continue
+		}
+		ownerRepo := strings.SplitN(strings.TrimPrefix(s.RepoName, p), "_", 2)
+		if len(ownerRepo) == 2 {
+			r.URL = fmt.Sprintf("%s/%s/%s", r.URL, ownerRepo[0], ownerRepo[1])
+			return r
+		}
+	}
+
+	if s.ProjectID != "" {
+		projectID = s.ProjectID
+	}
+	return Repository{
+		URL:  fmt.Sprintf("https://source.cloud.google.com/%s/%s", projectID, s.RepoName),
+		Host: CloudSourceRepositories,
+	}
+}
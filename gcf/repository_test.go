@@ -0,0 +1,205 @@
+package gcf
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRepository_URLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       Repository
+		wantBranch string
+		wantCommit string
+		wantPR     string
+	}{
+		{
+			"GitHub",
+			NewRepository("https://github.com/bm-sms/nomos"),
+			"https://github.com/bm-sms/nomos/tree/feature/a",
+			"https://github.com/bm-sms/nomos/commit/0123abc",
+			"https://github.com/bm-sms/nomos/pull/42",
+		},
+		{
+			"GitLab",
+			NewRepository("https://gitlab.com/bm-sms/nomos/"),
+			"https://gitlab.com/bm-sms/nomos/-/tree/feature/a",
+			"https://gitlab.com/bm-sms/nomos/-/commit/0123abc",
+			"https://gitlab.com/bm-sms/nomos/-/merge_requests/42",
+		},
+		{
+			"Bitbucket",
+			NewRepository("https://bitbucket.org/bm-sms/nomos"),
+			"https://bitbucket.org/bm-sms/nomos/branch/feature/a",
+			"https://bitbucket.org/bm-sms/nomos/commits/0123abc",
+			"https://bitbucket.org/bm-sms/nomos/pull-requests/42",
+		},
+		{
+			"Cloud Source Repositories",
+			NewRepository("https://source.cloud.google.com/nomos-sms/nomos"),
+			"https://source.cloud.google.com/nomos-sms/nomos/+/feature/a",
+			"https://source.cloud.google.com/nomos-sms/nomos/+/0123abc",
+			"",
+		},
+		{
+			"Self-hosted GitLab with explicit host",
+			Repository{URL: "https://git.example.com/bm-sms/nomos", Host: GitLab},
+			"https://git.example.com/bm-sms/nomos/-/tree/feature/a",
+			"https://git.example.com/bm-sms/nomos/-/commit/0123abc",
+			"https://git.example.com/bm-sms/nomos/-/merge_requests/42",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.repo.BranchURL("feature/a"); got != tt.wantBranch {
+				t.Errorf("Repository.BranchURL() = %v, want %v", got, tt.wantBranch)
+			}
+			if got := tt.repo.CommitURL("0123abc"); got != tt.wantCommit {
+				t.Errorf("Repository.CommitURL() = %v, want %v", got, tt.wantCommit)
+			}
+			if got := tt.repo.PullRequestURL("42"); got != tt.wantPR {
+				t.Errorf("Repository.PullRequestURL() = %v, want %v", got, tt.wantPR)
+			}
+		})
+	}
+}
+
+func TestBuildEvent_Repository(t *testing.T) {
+	c := &SlackConfig{
+		ProjectID: "nomos-sms",
+		File: FileConfig{
+			Repositories: map[string]Repository{
+				"nomos-api": {URL: "https://gitlab.com/bm-sms/nomos-api"},
+				"projects/nomos-sms/locations/asia-northeast1/connections/bb/repositories/nomos-web": {
+					URL: "https://bitbucket.org/bm-sms/nomos-web", Host: Bitbucket,
+				},
+			},
+		},
+	}
+	connected := func(name string) *BuildSource {
+		return &BuildSource{ConnectedRepository: &BuildConnectedRepository{Repository: name}}
+	}
+	repoSource := func(name string) *BuildSource {
+		return &BuildSource{RepoSource: &BuildRepoSource{RepoName: name}}
+	}
+
+	tests := []struct {
+		name   string
+		config *SlackConfig
+		source *BuildSource
+		sub    *BuildSubstitutions
+		want   Repository
+	}{
+		{
+			"Nothing tells the repository",
+			&SlackConfig{},
+			nil,
+			nil,
+			Repository{URL: "https://github.com/bm-sms/nomos", Host: GitHub},
+		},
+		{
+			"REPOSITORY_URL is given",
+			&SlackConfig{RepositoryURL: "https://gitlab.com/bm-sms/nomos"},
+			nil,
+			nil,
+			Repository{URL: "https://gitlab.com/bm-sms/nomos", Host: GitLab},
+		},
+		{
+			"REPO_NAME without the owner of REPOSITORY_URL",
+			&SlackConfig{RepositoryURL: "https://github.com"},
+			nil,
+			&BuildSubstitutions{RepoName: "nomos-batch"},
+			Repository{URL: "https://github.com", Host: GitHub},
+		},
+		{
+			"REPO_NAME without the host of REPOSITORY_URL",
+			&SlackConfig{RepositoryURL: "nomos"},
+			nil,
+			&BuildSubstitutions{RepoName: "nomos-batch"},
+			Repository{URL: "nomos", Host: GitHub},
+		},
+		{
+			"REPO_NAME in the config file",
+			c,
+			nil,
+			&BuildSubstitutions{RepoName: "nomos-api"},
+			Repository{URL: "https://gitlab.com/bm-sms/nomos-api"},
+		},
+		{
+			"REPO_NAME of the same owner",
+			c,
+			nil,
+			&BuildSubstitutions{RepoName: "nomos-batch"},
+			Repository{URL: "https://github.com/bm-sms/nomos-batch", Host: GitHub},
+		},
+		{
+			"Connected repository in the config file",
+			c,
+			connected("projects/nomos-sms/locations/asia-northeast1/connections/bb/repositories/nomos-web"),
+			&BuildSubstitutions{RepoName: "nomos-web"},
+			Repository{URL: "https://bitbucket.org/bm-sms/nomos-web", Host: Bitbucket},
+		},
+		{
+			"Connected repository by its ID",
+			c,
+			connected("projects/nomos-sms/locations/us-central1/connections/gl/repositories/nomos-api"),
+			nil,
+			Repository{URL: "https://gitlab.com/bm-sms/nomos-api"},
+		},
+		{
+			"Mirrored from GitHub",
+			c,
+			repoSource("github_bm-sms_nomos_tools"),
+			nil,
+			Repository{URL: "https://github.com/bm-sms/nomos_tools", Host: GitHub},
+		},
+		{
+			"Mirrored from Bitbucket",
+			c,
+			repoSource("bitbucket_bm-sms_nomos"),
+			nil,
+			Repository{URL: "https://bitbucket.org/bm-sms/nomos", Host: Bitbucket},
+		},
+		{
+			"Cloud Source Repositories",
+			c,
+			repoSource("nomos-infra"),
+			nil,
+			Repository{URL: "https://source.cloud.google.com/nomos-sms/nomos-infra", Host: CloudSourceRepositories},
+		},
+		{
+			"Developer Connect by its ID",
+			c,
+			&BuildSource{DeveloperConnect: &BuildDeveloperConnect{
+				GitRepositoryLink: "projects/nomos-sms/locations/asia-northeast1/connections/gl/gitRepositoryLinks/nomos-api",
+			}},
+			nil,
+			Repository{URL: "https://gitlab.com/bm-sms/nomos-api"},
+		},
+		{
+			"Git source",
+			c,
+			&BuildSource{GitSource: &BuildGitSource{URL: "https://gitlab.com/bm-sms/nomos-docs.git"}},
+			nil,
+			Repository{URL: "https://gitlab.com/bm-sms/nomos-docs", Host: GitLab},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				ProjectID:     "nomos-sms",
+				Source:        tt.source,
+				Substitutions: tt.sub,
+			}
+			got := e.Repository(tt.config)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("BuildEvent.Repository() = %v, want %v, differs: (-got +want;\n%s)", got, tt.want, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package gcf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+	"google.golang.org/api/googleapi"
+)
+
+// permanentError is a failure which retrying the event never fixes,
+// such as a broken message or a misconfiguration.
+// Errors which aren't marked as permanent are regarded as retryable.
+type permanentError struct {
+	err error
+}
+
+// Permanent marks err as permanent. It returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Cause() error  { return e.err }
+
+// Format keeps the stack trace of the wrapped error for %+v.
+func (e *permanentError) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	fmt.Fprint(s, e.err.Error())
+}
+
+// IsPermanent reports whether err or any of its causes is marked as permanent.
+func IsPermanent(err error) bool {
+	type causer interface {
+		Cause() error
+	}
+
+	for err != nil {
+		if _, ok := err.(*permanentError); ok {
+			return true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// isPermanentStatus reports whether a response with the HTTP status code never succeeds by retrying.
+func isPermanentStatus(code int) bool {
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests && code != http.StatusRequestTimeout
+}
+
+// classifyGoogleAPIError marks err of Google APIs as permanent by its status code.
+func classifyGoogleAPIError(err error) error {
+	if e, ok := errors.Cause(err).(*googleapi.Error); ok && isPermanentStatus(e.Code) {
+		return Permanent(err)
+	}
+	return err
+}
+
+type RetryConfig struct {
+	// MaxEventAge is how long a retryable failure of an event is retried.
+	MaxEventAge time.Duration `envconfig:"max_event_age" default:"1h"`
+}
+
+var (
+	retryConfig     RetryConfig
+	onceRetryConfig try.Once
+)
+
+func getRetryConfig() (*RetryConfig, error) {
+	err := onceRetryConfig.Try(func() error {
+		return envconfig.Process("", &retryConfig)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &retryConfig, nil
+}
+
+// handleError decides what a function returns to Cloud Functions.
+// Permanent failures and events older than MaxEventAge are only logged and return nil,
+// because Cloud Functions retries an event as long as an error is returned if retrying is enabled.
+func handleError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if IsPermanent(err) {
+		logger(ctx).Error(err, "Permanent failure, which is not retried")
+		return nil
+	}
+
+	c, cerr := getRetryConfig()
+	if cerr != nil {
+		logger(ctx).Error(cerr, "Failed to get config about retries")
+		logger(ctx).Error(err, "Failure, which is retried")
+		return err
+	}
+	meta, merr := metadata.FromContext(ctx)
+	if merr != nil {
+		logger(ctx).Error(err, "Failure, which is retried")
+		return err
+	}
+	if age := time.Since(meta.Timestamp); age > c.MaxEventAge {
+		logger(ctx).Error(err, fmt.Sprintf("Gave up retrying the event %s after %s", meta.EventID, age))
+		return nil
+	}
+
+	logger(ctx).Error(err, "Failure, which is retried")
+	return err
+}
@@ -0,0 +1,65 @@
+package gcf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"Unmarked error", errors.New("failed"), false},
+		{"Permanent error", Permanent(errors.New("failed")), true},
+		{"Wrapped permanent error", errors.Wrap(Permanent(errors.New("failed")), "wrapped"), true},
+		{"Permanent status of Google API", classifyGoogleAPIError(&googleapi.Error{Code: 403}), true},
+		{"Retryable status of Google API", classifyGoogleAPIError(&googleapi.Error{Code: 503}), false},
+		{"Rate limited by Google API", classifyGoogleAPIError(&googleapi.Error{Code: 429}), false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	withTimestamp := func(ts time.Time) context.Context {
+		return metadata.NewContext(context.Background(), &metadata.Metadata{EventID: "1", Timestamp: ts})
+	}
+	retryable := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		wantErr bool
+	}{
+		{"No errors", withTimestamp(time.Now()), nil, false},
+		{"Permanent error", withTimestamp(time.Now()), Permanent(retryable), false},
+		{"Retryable error of a new event", withTimestamp(time.Now().Add(-time.Minute)), retryable, true},
+		{"Retryable error of an old event", withTimestamp(time.Now().Add(-2 * time.Hour)), retryable, false},
+		{"Retryable error without metadata", context.Background(), retryable, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := handleError(tt.ctx, tt.err); (err != nil) != tt.wantErr {
+				t.Errorf("handleError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package gcf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Route sends builds matched with it to another channel or webhook, or nowhere with Drop.
+// Routes are tried in order and the first matched one is used.
+type Route struct {
+	Name    string     `yaml:"name"`
+	Match   RouteMatch `yaml:"match"`
+	Channel string     `yaml:"channel"`
+	Webhook string     `yaml:"webhook"`
+	Drop    bool       `yaml:"drop"`
+	// OnlyStateChanges notifies only builds which break or fix their branch, for noisy branches.
+	// A route with it can omit the destination to use the default one.
+	OnlyStateChanges bool `yaml:"onlyStateChanges"`
+	// Language of messages takes precedence over the one of the channel.
+	Language string `yaml:"language"`
+}
+
+// RouteMatch matches a build if all of the given conditions are satisfied.
+type RouteMatch struct {
+	// Branch is a glob pattern. "*" matches any characters including "/".
+	Branch string `yaml:"branch"`
+	// Tags matches if a build has any of them.
+	Tags      []string `yaml:"tags"`
+	TriggerID string   `yaml:"triggerId"`
+	// Statuses matches if a build is in any of them.
+	Statuses []string `yaml:"statuses"`
+}
+
+func (r Route) String() string {
+	if r.OnlyStateChanges {
+		return r.destination() + " only on state changes"
+	}
+	return r.destination()
+}
+
+func (r Route) destination() string {
+	switch {
+	case r.Drop:
+		return fmt.Sprintf("%s (drop)", r.Name)
+	case r.Channel != "" && r.Webhook != "":
+		return fmt.Sprintf("%s (channel: %s, %s)", r.Name, r.Channel, r.webhook())
+	case r.Channel != "":
+		return fmt.Sprintf("%s (channel: %s)", r.Name, r.Channel)
+	case r.Webhook == "":
+		return fmt.Sprintf("%s (default)", r.Name)
+	}
+	return fmt.Sprintf("%s (%s)", r.Name, r.webhook())
+}
+
+// webhook shows only the name of the secret, because the path of webhooks is a secret.
+func (r Route) webhook() string {
+	if strings.HasPrefix(r.Webhook, SecretScheme) {
+		return "webhook: " + r.Webhook
+	}
+	return "webhook"
+}
+
+func (r Route) validate() error {
+	if r.Drop && (r.Channel != "" || r.Webhook != "") {
+		return errors.Errorf("route %s drops builds, but has a destination", r.Name)
+	}
+	if r.Drop && r.OnlyStateChanges {
+		return errors.Errorf("route %s drops builds, but notifies state changes", r.Name)
+	}
+	if !r.Drop && !r.OnlyStateChanges && r.Channel == "" && r.Webhook == "" {
+		return errors.Errorf("route %s has no destination", r.Name)
+	}
+	for _, s := range r.Match.Statuses {
+		if _, ok := statusMap[s]; !ok {
+			return errors.Errorf("%s is unknown status in route %s", s, r.Name)
+		}
+	}
+	if r.Language != "" && !validLanguage(r.Language) {
+		return errors.Errorf("%s is unknown language in route %s", r.Language, r.Name)
+	}
+	return nil
+}
+
+func (m RouteMatch) matches(b BuildEvent) bool {
+	if m.Branch != "" && !globToRegexp(m.Branch).MatchString(string(b.Branch())) {
+		return false
+	}
+	if len(m.Tags) > 0 {
+		if b.Tags == nil {
+			return false
+		}
+		found := false
+		for _, t := range m.Tags {
+			found = found || b.Tags.includedTag(t)
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.TriggerID != "" && m.TriggerID != b.BuildTriggerID {
+		return false
+	}
+	if len(m.Statuses) > 0 && !includedStatus(m.Statuses, b.Status) {
+		return false
+	}
+	return true
+}
+
+func globToRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// MatchRoute returns the first route matched with b, or nil if nothing matches.
+func (c *FileConfig) MatchRoute(b BuildEvent) *Route {
+	for i := range c.Routes {
+		if c.Routes[i].Match.matches(b) {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// WithRoute returns a copy of c whose destination is replaced by r.
+// The language is the one of r, the one of the channel or LANGUAGE in order.
+func (c *SlackConfig) WithRoute(r *Route) *SlackConfig {
+	routed := *c
+	if r != nil && r.Channel != "" {
+		routed.SlackChannel = r.Channel
+	}
+	if r != nil && r.Webhook != "" {
+		routed.SlackWebhook = r.Webhook
+		routed.forgetSecret("SLACK_WEBHOOK")
+	}
+	if lang, ok := c.File.ChannelLanguages[routed.SlackChannel]; ok {
+		routed.Language = lang
+	}
+	if r != nil && r.Language != "" {
+		routed.Language = r.Language
+	}
+	return &routed
+}
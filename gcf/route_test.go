@@ -0,0 +1,140 @@
+package gcf
+
+import (
+	"testing"
+)
+
+func TestFileConfig_MatchRoute(t *testing.T) {
+	c, err := LoadFileConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		branch  string
+		tags    *BuildTags
+		trigger string
+		status  string
+		want    string
+	}{
+		{"Dependabot", "dependabot/go_modules/github.com/pkg/errors-0.8.1", nil, "", "SUCCESS", "dependabot"},
+		{"Deploy failure of master", "master", &BuildTags{"test", "deploy-admin-service"}, "", "FAILURE", "master-deploy-failure"},
+		{"Deploy success of master", "master", &BuildTags{"deploy-admin-service"}, "", "SUCCESS", ""},
+		{"Failure of master without tags", "master", nil, "", "FAILURE", ""},
+		{"Feature branch", "feature/slack", nil, "", "SUCCESS", "#3"},
+		{"Nightly trigger", "dev", nil, "0f9b3c4e-1a2b-4c5d-8e7f-9a0b1c2d3e4f", "SUCCESS", "nightly"},
+		{"Nothing matches", "dev", nil, "", "SUCCESS", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				Status:         tt.status,
+				BuildTriggerID: tt.trigger,
+				Source:         &BuildSource{RepoSource: &BuildRepoSource{BranchName: tt.branch}},
+				Tags:           tt.tags,
+			}
+			var got string
+			if r := c.MatchRoute(e); r != nil {
+				got = r.Name
+			}
+			if got != tt.want {
+				t.Errorf("FileConfig.MatchRoute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute_String(t *testing.T) {
+	tests := []struct {
+		route Route
+		want  string
+	}{
+		{Route{Name: "incidents", Channel: "#incidents"}, "incidents (channel: #incidents)"},
+		{Route{Name: "nightly", Webhook: "T00000000/B00000000/XXXXXXXXXXXXXXXXXXXXXXXX"}, "nightly (webhook)"},
+		{Route{Name: "nightly", Webhook: "secret://slack-nightly"}, "nightly (webhook: secret://slack-nightly)"},
+		{Route{Name: "both", Channel: "#ci", Webhook: "T00000000/B00000000/XXXXXXXXXXXXXXXXXXXXXXXX"}, "both (channel: #ci, webhook)"},
+		{Route{Name: "feature", OnlyStateChanges: true}, "feature (default) only on state changes"},
+		{Route{Name: "dependabot", Drop: true}, "dependabot (drop)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.route.String(); got != tt.want {
+				t.Errorf("Route.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   Route
+		wantErr bool
+	}{
+		{"Channel", Route{Channel: "#ci"}, false},
+		{"Webhook", Route{Webhook: "T/B/X"}, false},
+		{"Drop", Route{Drop: true}, false},
+		{"No destination", Route{}, true},
+		{"Drop with destination", Route{Drop: true, Channel: "#ci"}, true},
+		{"Only state changes", Route{OnlyStateChanges: true}, false},
+		{"Drop state changes", Route{Drop: true, OnlyStateChanges: true}, true},
+		{"Unknown status", Route{Channel: "#ci", Match: RouteMatch{Statuses: []string{"FAILED"}}}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.route.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Route.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSlackConfig_WithRoute(t *testing.T) {
+	c := &SlackConfig{SlackWebhook: "default", SlackChannel: "#ci"}
+
+	got := c.WithRoute(&Route{Channel: "#incidents"})
+	if got.SlackChannel != "#incidents" || got.SlackWebhook != "default" {
+		t.Errorf("SlackConfig.WithRoute() = %+v, want only the channel replaced", got)
+	}
+	got = c.WithRoute(nil)
+	if got.SlackChannel != "#ci" || got.SlackWebhook != "default" {
+		t.Errorf("SlackConfig.WithRoute(nil) = %+v, want the same destination", got)
+	}
+	if c.SlackChannel != "#ci" {
+		t.Errorf("SlackConfig.WithRoute() changes the original config")
+	}
+}
+
+func TestSlackConfig_WithRoute_language(t *testing.T) {
+	c := &SlackConfig{
+		SlackChannel: "#ci",
+		Language:     English,
+		File:         FileConfig{ChannelLanguages: map[string]string{"#ci-ja": Japanese}},
+	}
+	tests := []struct {
+		name  string
+		route *Route
+		want  string
+	}{
+		{"Default", nil, English},
+		{"Channel", &Route{Channel: "#ci-ja"}, Japanese},
+		{"Route", &Route{Channel: "#ci", Language: Japanese}, Japanese},
+		{"Route takes precedence over the channel", &Route{Channel: "#ci-ja", Language: English}, English},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := c.WithRoute(tt.route).language(); got != tt.want {
+				t.Errorf("SlackConfig.WithRoute().language() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
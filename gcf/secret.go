@@ -0,0 +1,275 @@
+package gcf
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tenntenn/sync/try"
+	"golang.org/x/oauth2/google"
+)
+
+// SecretScheme prefixes references to secrets in settings such as SLACK_WEBHOOK=secret://slack-webhook.
+const SecretScheme = "secret://"
+
+// secretManagerBaseURL is replaced in tests.
+var secretManagerBaseURL = "https://secretmanager.googleapis.com/"
+
+// SecretProvider returns the value of a secret by its name.
+// Values are trimmed of spaces such as newlines at the end of files and echo.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// EnvSecretProvider reads secrets from environment variables named after them.
+type EnvSecretProvider struct{}
+
+func (p EnvSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", Permanent(errors.Errorf("Environment variable %s is not set", name))
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// FileSecretProvider reads secrets from files in Dir such as secrets mounted as volumes.
+type FileSecretProvider struct {
+	Dir string
+}
+
+func (p FileSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(p.Dir, filepath.Clean("/"+name)))
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read secret %s", name)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// MemorySecretProvider is a fake of providers for local runs and tests.
+type MemorySecretProvider map[string]string
+
+func (p MemorySecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return "", Permanent(errors.Errorf("%s is unknown secret", name))
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// SecretManagerProvider accesses versions of secrets in Secret Manager.
+// Names are secrets of ProjectID like "slack-webhook", or resource names like
+// "projects/<project>/secrets/<secret>/versions/<version>". The latest version is used unless specified.
+type SecretManagerProvider struct {
+	ProjectID  string
+	HTTPClient *http.Client
+}
+
+func NewSecretManagerProvider(ctx context.Context, projectID string) (*SecretManagerProvider, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create a Google client")
+	}
+	return &SecretManagerProvider{ProjectID: projectID, HTTPClient: client}, nil
+}
+
+func (p *SecretManagerProvider) version(name string) string {
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%s/secrets/%s", p.ProjectID, name)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name
+}
+
+func (p *SecretManagerProvider) Secret(ctx context.Context, name string) (string, error) {
+	v := p.version(name)
+	req, err := http.NewRequest(http.MethodGet, secretManagerBaseURL+"v1/"+v+":access", nil)
+	if err != nil {
+		return "", Permanent(errors.WithStack(err))
+	}
+	res, err := p.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to access %s", v)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err := errors.Errorf("Secret Manager responded %d to access %s", res.StatusCode, v)
+		if isPermanentStatus(res.StatusCode) {
+			return "", Permanent(err)
+		}
+		return "", err
+	}
+
+	// See https://cloud.google.com/secret-manager/docs/reference/rest/v1/projects.secrets.versions/access
+	var access struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&access); err != nil {
+		return "", errors.Wrapf(err, "Failed to decode %s", v)
+	}
+	b, err := base64.StdEncoding.DecodeString(access.Payload.Data)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to decode the payload of %s", v)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// SecretCache caches secrets of Provider for TTL, so that rotated secrets are used without redeploys.
+type SecretCache struct {
+	Provider SecretProvider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]cachedSecret
+	now     func() time.Time
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+func NewSecretCache(p SecretProvider, ttl time.Duration) *SecretCache {
+	return &SecretCache{Provider: p, TTL: ttl, secrets: map[string]cachedSecret{}, now: time.Now}
+}
+
+func (c *SecretCache) Get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.secrets[name]; ok && c.now().Sub(s.fetchedAt) < c.TTL {
+		return s.value, nil
+	}
+	v, err := c.Provider.Secret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	c.secrets[name] = cachedSecret{value: v, fetchedAt: c.now()}
+	return v, nil
+}
+
+// Invalidate makes the next Get fetch the secret again.
+func (c *SecretCache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.secrets, name)
+}
+
+var (
+	secretCache     *SecretCache
+	onceSecretCache try.Once
+)
+
+func getSecretCache(ctx context.Context, c *SlackConfig) (*SecretCache, error) {
+	err := onceSecretCache.Try(func() error {
+		p, err := newSecretProvider(ctx, c)
+		if err != nil {
+			return err
+		}
+		secretCache = NewSecretCache(p, c.SecretTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return secretCache, nil
+}
+
+func newSecretProvider(ctx context.Context, c *SlackConfig) (SecretProvider, error) {
+	switch c.SecretProvider {
+	case "env":
+		return EnvSecretProvider{}, nil
+	case "file":
+		return FileSecretProvider{Dir: c.SecretDir}, nil
+	case "secretmanager":
+		return NewSecretManagerProvider(ctx, c.homeProject())
+	}
+	return nil, errors.Errorf("%s is unknown secret provider", c.SecretProvider)
+}
+
+// secretFields are settings which can be references to secrets, keyed by their environment variables.
+func (c *SlackConfig) secretFields() map[string]*string {
+	return map[string]*string{
+		"SLACK_WEBHOOK":        &c.SlackWebhook,
+		"SLACK_BOT_TOKEN":      &c.SlackBotToken,
+		"SLACK_SIGNING_SECRET": &c.SlackSigningSecret,
+	}
+}
+
+// withSecrets returns a copy of c whose references to secrets are resolved.
+// Resolved references are remembered to fetch them again with refreshSecrets.
+func (c *SlackConfig) withSecrets(ctx context.Context) (*SlackConfig, error) {
+	resolved := *c
+	resolved.secretRefs = map[string]string{}
+	for k, ref := range c.secretRefs {
+		resolved.secretRefs[k] = ref
+	}
+
+	for k, f := range resolved.secretFields() {
+		if !strings.HasPrefix(*f, SecretScheme) {
+			continue
+		}
+		cache, err := getSecretCache(ctx, c)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get the secret provider")
+		}
+		name := strings.TrimPrefix(*f, SecretScheme)
+		v, err := cache.Get(ctx, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get secret %s of %s", name, k)
+		}
+		*f = v
+		resolved.secretRefs[k] = name
+	}
+	return &resolved, nil
+}
+
+// refreshSecrets returns a copy of c with secrets fetched again, such as after Slack rejected them.
+func (c *SlackConfig) refreshSecrets(ctx context.Context) (*SlackConfig, error) {
+	if len(c.secretRefs) == 0 {
+		return c, nil
+	}
+	cache, err := getSecretCache(ctx, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get the secret provider")
+	}
+
+	refreshed := *c
+	fields := refreshed.secretFields()
+	for k, name := range c.secretRefs {
+		cache.Invalidate(name)
+		v, err := cache.Get(ctx, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get secret %s of %s", name, k)
+		}
+		*fields[k] = v
+	}
+	return &refreshed, nil
+}
+
+// forgetSecret stops refreshing the setting, because it is replaced such as by a route.
+func (c *SlackConfig) forgetSecret(key string) {
+	if _, ok := c.secretRefs[key]; !ok {
+		return
+	}
+	refs := map[string]string{}
+	for k, ref := range c.secretRefs {
+		if k != key {
+			refs[k] = ref
+		}
+	}
+	c.secretRefs = refs
+}
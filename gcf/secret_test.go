@@ -0,0 +1,193 @@
+package gcf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type countingSecretProvider struct {
+	MemorySecretProvider
+	calls int
+}
+
+func (p *countingSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	p.calls++
+	return p.MemorySecretProvider.Secret(ctx, name)
+}
+
+func TestSecretCache(t *testing.T) {
+	ctx := context.Background()
+	p := &countingSecretProvider{MemorySecretProvider: MemorySecretProvider{"slack-webhook": "T0/B0/old"}}
+	now := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	c := NewSecretCache(p, 10*time.Minute)
+	c.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		elapsed   time.Duration
+		rotated   string
+		invalid   bool
+		want      string
+		wantCalls int
+	}{
+		{"First", 0, "", false, "T0/B0/old", 1},
+		{"Cached", 5 * time.Minute, "T0/B0/new", false, "T0/B0/old", 1},
+		{"Expired", 6 * time.Minute, "", false, "T0/B0/new", 2},
+		{"Invalidated", 0, "T0/B0/newer", true, "T0/B0/newer", 3},
+	}
+	for _, st := range steps {
+		now = now.Add(st.elapsed)
+		if st.rotated != "" {
+			p.MemorySecretProvider["slack-webhook"] = st.rotated
+		}
+		if st.invalid {
+			c.Invalidate("slack-webhook")
+		}
+		got, err := c.Get(ctx, "slack-webhook")
+		if err != nil {
+			t.Fatalf("%s: SecretCache.Get() error = %v", st.name, err)
+		}
+		if got != st.want || p.calls != st.wantCalls {
+			t.Errorf("%s: SecretCache.Get() = %s after %d calls, want %s after %d calls", st.name, got, p.calls, st.want, st.wantCalls)
+		}
+	}
+
+	if _, err := c.Get(ctx, "unknown"); !IsPermanent(err) {
+		t.Errorf("SecretCache.Get() error = %v, want a permanent error", err)
+	}
+}
+
+func TestEnvSecretProvider(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_TEST", "T0/B0/XXX\n")
+
+	p := EnvSecretProvider{}
+	got, err := p.Secret(context.Background(), "SLACK_WEBHOOK_TEST")
+	if err != nil || got != "T0/B0/XXX" {
+		t.Errorf("EnvSecretProvider.Secret() = %v, %v, want T0/B0/XXX", got, err)
+	}
+	if _, err := p.Secret(context.Background(), "SLACK_BOT_TOKEN_TEST"); !IsPermanent(err) {
+		t.Errorf("EnvSecretProvider.Secret() error = %v, want a permanent error", err)
+	}
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "slack-webhook"), []byte("T0/B0/XXX\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := FileSecretProvider{Dir: dir}
+	got, err := p.Secret(context.Background(), "slack-webhook")
+	if err != nil || got != "T0/B0/XXX" {
+		t.Errorf("FileSecretProvider.Secret() = %v, %v, want T0/B0/XXX", got, err)
+	}
+	if _, err := p.Secret(context.Background(), "slack-bot-token"); err == nil {
+		t.Errorf("FileSecretProvider.Secret() returns no errors for a missing secret")
+	}
+}
+
+func TestSecretManagerProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects/nomos-sms/secrets/slack-webhook/versions/latest:access",
+			"/v1/projects/shared/secrets/slack-webhook/versions/3:access":
+			fmt.Fprint(w, `{"name":"x","payload":{"data":"VDAvQjAvWFhY"}}`)
+		case "/v1/projects/nomos-sms/secrets/slack-webhook-newline/versions/latest:access":
+			fmt.Fprint(w, `{"name":"x","payload":{"data":"VDAvQjAvWFhYCg=="}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	orig := secretManagerBaseURL
+	secretManagerBaseURL = ts.URL + "/"
+	defer func() { secretManagerBaseURL = orig }()
+
+	p := &SecretManagerProvider{ProjectID: "nomos-sms", HTTPClient: ts.Client()}
+	for _, name := range []string{"slack-webhook", "projects/shared/secrets/slack-webhook/versions/3", "slack-webhook-newline"} {
+		got, err := p.Secret(context.Background(), name)
+		if err != nil || got != "T0/B0/XXX" {
+			t.Errorf("SecretManagerProvider.Secret(%s) = %v, %v, want T0/B0/XXX", name, got, err)
+		}
+	}
+	if _, err := p.Secret(context.Background(), "unknown"); !IsPermanent(err) {
+		t.Errorf("SecretManagerProvider.Secret() error = %v, want a permanent error", err)
+	}
+}
+
+func TestSlackNotifier_refreshesSecrets(t *testing.T) {
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer xoxb-new" {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"channel":"C12345","ts":"1550000000.000100"}`)
+	}))
+	defer ts.Close()
+	orig := slackAPIBaseURL
+	slackAPIBaseURL = ts.URL + "/api/"
+	defer func() { slackAPIBaseURL = orig }()
+
+	// The token has been rotated since it was cached.
+	cache := NewSecretCache(MemorySecretProvider{"slack-bot-token": "xoxb-new"}, time.Hour)
+	cache.secrets["slack-bot-token"] = cachedSecret{value: "xoxb-old", fetchedAt: time.Now()}
+	if err := onceSecretCache.Try(func() error {
+		secretCache = cache
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &SlackConfig{SlackBotToken: "secret://slack-bot-token", SlackChannel: "#ci", MessageStore: "memory"}
+	b := BuildEvent{ID: "refreshed-build-id", Status: "SUCCESS"}
+	if err := (&SlackNotifier{}).Notify(context.Background(), b, c); err != nil {
+		t.Fatalf("SlackNotifier.Notify() error = %+v", err)
+	}
+
+	want := []string{"Bearer xoxb-old", "Bearer xoxb-new"}
+	if diff := cmp.Diff(tokens, want); diff != "" {
+		t.Errorf("Authorization headers = %v, want %v, differs: (-got +want;\n%s)", tokens, want, diff)
+	}
+	if c.SlackBotToken != "secret://slack-bot-token" {
+		t.Errorf("SlackNotifier.Notify() changes the config")
+	}
+}
+
+func TestIsRejectedBySlack(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Removed webhook", &SlackError{StatusCode: http.StatusNotFound}, true},
+		{"Unauthorized", &SlackError{StatusCode: http.StatusUnauthorized}, true},
+		{"Server error", &SlackError{StatusCode: http.StatusInternalServerError}, false},
+		{"Revoked token", &SlackError{StatusCode: http.StatusOK, APIError: "token_revoked"}, true},
+		{"Missing channel", &SlackError{StatusCode: http.StatusOK, APIError: "channel_not_found"}, false},
+		{"Other errors", fmt.Errorf("failed"), false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := isRejectedBySlack(Permanent(tt.err)); got != tt.want {
+				t.Errorf("isRejectedBySlack() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
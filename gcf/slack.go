@@ -0,0 +1,83 @@
+package gcf
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SlackNotifier posts a build result to the Incoming Webhook of Slack,
+// or keeps a single message updated with the bot token.
+type SlackNotifier struct{}
+
+// Notify resolves secrets of routes, and fetches secrets again once if Slack rejects them,
+// because they may be rotated.
+func (n *SlackNotifier) Notify(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+	c, err := c.withSecrets(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve secrets")
+	}
+
+	err = n.notify(ctx, b, c)
+	if len(c.secretRefs) == 0 || !isRejectedBySlack(err) {
+		return err
+	}
+	logger(ctx).Warningf("Fetching secrets again, because Slack rejected them: %v", err)
+	c, rerr := c.refreshSecrets(ctx)
+	if rerr != nil {
+		logger(ctx).Error(rerr, "Failed to refresh secrets")
+		return err
+	}
+	return n.notify(ctx, b, c)
+}
+
+func (n *SlackNotifier) notify(ctx context.Context, b BuildEvent, c *SlackConfig) error {
+	msg := RenderSlackMessage(b, c)
+	if c.UpdatesMessage() {
+		store, err := getMessageStore(ctx, c)
+		if err != nil {
+			return errors.Wrap(err, "Failed to get the message store")
+		}
+		return updateSlackMessage(ctx, store, b, c, msg)
+	}
+
+	if err := defaultSlackClient.PostWebhook(ctx, c.SlackWebhookURL(), msg); err != nil {
+		return errors.Wrap(err, "Failed to send a message to Slack")
+	}
+	logger(ctx).Infof("Sent a message to Slack")
+
+	return nil
+}
+
+// updateSlackMessage posts the first message of a build, then updates it on later statuses.
+// An in-progress status delivered after the final one is ignored.
+func updateSlackMessage(ctx context.Context, store MessageStore, b BuildEvent, c *SlackConfig, msg SlackMessage) error {
+	ref, err := store.Get(ctx, b.ID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the posted message")
+	}
+
+	if ref == nil {
+		msg.Channel = c.SlackChannel
+		res, err := defaultSlackClient.Call(ctx, c.SlackBotToken, "chat.postMessage", msg)
+		if err != nil {
+			return errors.Wrap(err, "Failed to post a message to Slack")
+		}
+		logger(ctx).Infof("Posted a message to Slack: %s", res.TS)
+		return store.Put(ctx, b.ID, SlackMessageRef{Channel: res.Channel, TS: res.TS, Status: b.Status})
+	}
+
+	if b.IsInProgress() && !isInProgress(ref.Status) {
+		logger(ctx).Infof("%s is older than the posted status %s", b.Status, ref.Status)
+		return nil
+	}
+
+	msg.Channel = ref.Channel
+	msg.TS = ref.TS
+	if _, err := defaultSlackClient.Call(ctx, c.SlackBotToken, "chat.update", msg); err != nil {
+		return errors.Wrap(err, "Failed to update a message of Slack")
+	}
+	logger(ctx).Infof("Updated a message of Slack: %s", ref.TS)
+	ref.Status = b.Status
+	return store.Put(ctx, b.ID, *ref)
+}
@@ -0,0 +1,55 @@
+package gcf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUpdateSlackMessage(t *testing.T) {
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer xoxb-token" {
+			t.Errorf("Authorization header = %s", got)
+		}
+		msg := SlackMessage{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+			return
+		}
+		calls = append(calls, fmt.Sprintf("%s %s %s", r.URL.Path, msg.Channel, msg.TS))
+		fmt.Fprint(w, `{"ok":true,"channel":"C12345","ts":"1550000000.000100"}`)
+	}))
+	defer ts.Close()
+	orig := slackAPIBaseURL
+	slackAPIBaseURL = ts.URL + "/api/"
+	defer func() { slackAPIBaseURL = orig }()
+
+	store := NewMemoryMessageStore()
+	c := &SlackConfig{SlackBotToken: "xoxb-token", SlackChannel: "#ci"}
+	for _, status := range []string{"QUEUED", "WORKING", "SUCCESS", "WORKING"} {
+		b := BuildEvent{ID: "build-id", Status: status}
+		if err := updateSlackMessage(context.Background(), store, b, c, SlackMessage{}); err != nil {
+			t.Fatalf("updateSlackMessage() with %s returns an error: %+v", status, err)
+		}
+	}
+
+	want := []string{
+		"/api/chat.postMessage #ci ",
+		"/api/chat.update C12345 1550000000.000100",
+		"/api/chat.update C12345 1550000000.000100",
+	}
+	if diff := cmp.Diff(calls, want); diff != "" {
+		t.Errorf("Slack API calls = %v, want %v, differs: (-got +want;\n%s)", calls, want, diff)
+	}
+
+	ref, _ := store.Get(context.Background(), "build-id")
+	if ref.Status != "SUCCESS" {
+		t.Errorf("The stored status = %s, want SUCCESS", ref.Status)
+	}
+}
@@ -0,0 +1,236 @@
+package gcf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// slackAPIBaseURL is replaced in tests.
+var slackAPIBaseURL = "https://slack.com/api/"
+
+// defaultSlackClient is used by SlackNotifier.
+var defaultSlackClient = &SlackClient{
+	HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// SlackClient sends messages to Incoming Webhooks and the Web API of Slack.
+// It retries 5xx responses with jittered exponential backoff and 429 responses after Retry-After,
+// as long as the deadline of the context allows. Retry-After longer than MaxDelay is not waited for,
+// and the retryable error is returned to retry the delivery later.
+type SlackClient struct {
+	HTTPClient *http.Client
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// SlackError is a failed request to Slack.
+type SlackError struct {
+	// Endpoint is a method of the Web API or "webhook", which never has the secret URL.
+	Endpoint   string
+	StatusCode int
+	// APIError is the error of the Web API such as channel_not_found.
+	APIError string
+	Body     string
+	Attempts int
+}
+
+func (e *SlackError) Error() string {
+	if e.APIError != "" {
+		return fmt.Sprintf("%s of Slack failed after %d attempt(s): %s", e.Endpoint, e.Attempts, e.APIError)
+	}
+	return fmt.Sprintf("%s of Slack responded %d after %d attempt(s): %s", e.Endpoint, e.StatusCode, e.Attempts, e.Body)
+}
+
+// retryableSlackErrors are errors of the Web API which can be fixed by retrying.
+// The others such as channel_not_found and invalid_auth never succeed.
+var retryableSlackErrors = map[string]bool{
+	"ratelimited":         true,
+	"request_timeout":     true,
+	"service_unavailable": true,
+	"fatal_error":         true,
+	"internal_error":      true,
+}
+
+// rejectedSlackErrors are errors of the Web API with revoked or wrong tokens.
+var rejectedSlackErrors = map[string]bool{
+	"not_authed":       true,
+	"invalid_auth":     true,
+	"token_revoked":    true,
+	"token_expired":    true,
+	"account_inactive": true,
+}
+
+// isRejectedBySlack reports whether Slack rejected the webhook or the token, which may be rotated.
+// Removed webhooks respond 404 and disabled ones respond 403.
+func isRejectedBySlack(err error) bool {
+	e, ok := errors.Cause(err).(*SlackError)
+	if !ok {
+		return false
+	}
+	if e.APIError != "" {
+		return rejectedSlackErrors[e.APIError]
+	}
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+func (e *SlackError) permanent() bool {
+	if e.APIError != "" {
+		return !retryableSlackErrors[e.APIError]
+	}
+	return isPermanentStatus(e.StatusCode)
+}
+
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// PostWebhook posts msg to the Incoming Webhook at webhookURL.
+func (c *SlackClient) PostWebhook(ctx context.Context, webhookURL string, msg SlackMessage) error {
+	_, err := c.post(ctx, "webhook", webhookURL, "", msg)
+	return err
+}
+
+// Call calls a method of the Web API with the bot token.
+// See https://api.slack.com/web
+func (c *SlackClient) Call(ctx context.Context, token, method string, msg SlackMessage) (*slackAPIResponse, error) {
+	body, err := c.post(ctx, method, slackAPIBaseURL+method, token, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &slackAPIResponse{}
+	if err := json.Unmarshal(body, r); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode a response of Slack")
+	}
+	if !r.OK {
+		return nil, c.fail(&SlackError{Endpoint: method, StatusCode: http.StatusOK, APIError: r.Error, Attempts: 1})
+	}
+	return r, nil
+}
+
+func (c *SlackClient) fail(e *SlackError) error {
+	err := errors.WithStack(e)
+	if e.permanent() {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (c *SlackClient) post(ctx context.Context, endpoint, endpointURL, token string, msg SlackMessage) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, Permanent(errors.Wrap(err, "Failed to encode a message to JSON"))
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, endpointURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, Permanent(errors.Wrapf(hideURL(err), "Failed to create a request to %s of Slack", endpoint))
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		var delay time.Duration
+		var failure error
+		res, err := c.httpClient().Do(req.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.Wrapf(ctx.Err(), "Gave up %s of Slack", endpoint)
+			}
+			failure = errors.Wrapf(hideURL(err), "Failed to request %s of Slack", endpoint)
+			delay = c.backoff(attempt)
+		} else {
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return b, nil
+			}
+
+			e := &SlackError{Endpoint: endpoint, StatusCode: res.StatusCode, Body: string(b), Attempts: attempt}
+			failure = c.fail(e)
+			switch {
+			case res.StatusCode == http.StatusTooManyRequests:
+				delay = retryAfter(res.Header.Get("Retry-After"), c.backoff(attempt))
+				if c.MaxDelay > 0 && delay > c.MaxDelay {
+					return nil, failure
+				}
+			case res.StatusCode >= 500:
+				delay = c.backoff(attempt)
+			default:
+				return nil, failure
+			}
+		}
+
+		if attempt > c.MaxRetries {
+			return nil, failure
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return nil, failure
+		}
+		select {
+		case <-ctx.Done():
+			return nil, failure
+		case <-time.After(delay):
+		}
+	}
+}
+
+// hideURL drops the URL from errors of requests, because the URL of a webhook is a secret.
+func hideURL(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return errors.Errorf("%s: %v", e.Op, e.Err)
+	}
+	return err
+}
+
+func (c *SlackClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// backoff returns a random delay up to BaseDelay * 2^(attempt-1) capped by MaxDelay.
+func (c *SlackClient) backoff(attempt int) time.Duration {
+	d := c.BaseDelay << uint(attempt-1)
+	if c.MaxDelay > 0 && (d > c.MaxDelay || d <= 0) {
+		d = c.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	//nolint[:gosec]
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retryAfter parses the Retry-After header in seconds.
+func retryAfter(header string, fallback time.Duration) time.Duration {
+	sec, err := strconv.Atoi(header)
+	if err != nil || sec < 0 {
+		return fallback
+	}
+	return time.Duration(sec) * time.Second
+}
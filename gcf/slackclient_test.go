@@ -0,0 +1,174 @@
+package gcf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSlackClient_PostWebhook(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []int
+		retryAfter    string
+		wantAttempts  int32
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{"Success", []int{200}, "", 1, false, false},
+		{"Retry 5xx", []int{500, 503, 200}, "", 3, false, false},
+		{"Retry 429 after Retry-After", []int{429, 200}, "0", 2, false, false},
+		{"Give up 5xx", []int{500, 500, 500, 500}, "", 3, true, false},
+		{"Never retry 404", []int{404, 200}, "", 1, true, true},
+		{"Never retry 403", []int{403, 200}, "", 1, true, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer ts.Close()
+
+			c := &SlackClient{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+			err := c.PostWebhook(context.Background(), ts.URL, SlackMessage{Text: "test"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SlackClient.PostWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := IsPermanent(err); got != tt.wantPermanent {
+				t.Errorf("IsPermanent(SlackClient.PostWebhook()) = %v, want %v", got, tt.wantPermanent)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("SlackClient.PostWebhook() attempted %d times, want %d", got, tt.wantAttempts)
+			}
+			if e, ok := errors.Cause(err).(*SlackError); tt.wantErr && (!ok || e.Attempts != int(tt.wantAttempts)) {
+				t.Errorf("SlackClient.PostWebhook() error = %#v, want SlackError of %d attempts", err, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSlackClient_PostWebhook_deadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	c := &SlackClient{MaxRetries: 3, BaseDelay: time.Millisecond}
+	err := c.PostWebhook(ctx, ts.URL, SlackMessage{})
+	if err == nil {
+		t.Fatal("SlackClient.PostWebhook() returns no errors")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("SlackClient.PostWebhook() waited %s beyond the deadline", d)
+	}
+	if IsPermanent(err) {
+		t.Errorf("SlackClient.PostWebhook() returns a permanent error for 429: %v", err)
+	}
+}
+
+func TestSlackClient_PostWebhook_retryAfter(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	start := time.Now()
+	c := &SlackClient{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	err := c.PostWebhook(context.Background(), ts.URL, SlackMessage{})
+	if err == nil {
+		t.Fatal("SlackClient.PostWebhook() returns no errors")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond || requests != 1 {
+		t.Errorf("SlackClient.PostWebhook() waited %s for %d requests beyond MaxDelay", d, requests)
+	}
+	if IsPermanent(err) {
+		t.Errorf("SlackClient.PostWebhook() returns a permanent error for 429: %v", err)
+	}
+}
+
+func TestSlackClient_PostWebhook_hidesURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed := ts.URL
+	ts.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"Connection refused", closed + "/services/T000/B000/SECRETSECRET"},
+		{"Invalid URL", "https://hooks.slack.com/services/T000/B000/SECRETSECRET\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := &SlackClient{MaxRetries: 0}
+			err := c.PostWebhook(context.Background(), tt.url, SlackMessage{Text: "test"})
+			if err == nil {
+				t.Fatal("SlackClient.PostWebhook() returns no errors")
+			}
+			if msg := fmt.Sprintf("%+v", err); strings.Contains(msg, "SECRETSECRET") {
+				t.Errorf("SlackClient.PostWebhook() error = %v, want no paths of the webhook", msg)
+			}
+		})
+	}
+}
+
+func TestSlackClient_Call(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{"OK", `{"ok":true,"channel":"C12345","ts":"1550000000.000100"}`, false, false},
+		{"Channel not found", `{"ok":false,"error":"channel_not_found"}`, true, true},
+		{"Internal error", `{"ok":false,"error":"internal_error"}`, true, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.response)
+			}))
+			defer ts.Close()
+			orig := slackAPIBaseURL
+			slackAPIBaseURL = ts.URL + "/"
+			defer func() { slackAPIBaseURL = orig }()
+
+			c := &SlackClient{}
+			res, err := c.Call(context.Background(), "xoxb-token", "chat.postMessage", SlackMessage{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SlackClient.Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := IsPermanent(err); got != tt.wantPermanent {
+				t.Errorf("IsPermanent(SlackClient.Call()) = %v, want %v", got, tt.wantPermanent)
+			}
+			if err == nil && res.TS != "1550000000.000100" {
+				t.Errorf("SlackClient.Call() = %v", res)
+			}
+		})
+	}
+}
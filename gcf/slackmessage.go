@@ -0,0 +1,245 @@
+package gcf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SlackMessage is a message of Slack composed with Block Kit.
+// See https://api.slack.com/block-kit
+type SlackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	TS      string `json:"ts,omitempty"`
+	// ReplaceOriginal and ResponseType are for response_url of interactions.
+	ReplaceOriginal bool              `json:"replace_original,omitempty"`
+	ResponseType    string            `json:"response_type,omitempty"`
+	Username        string            `json:"username,omitempty"`
+	IconEmoji       string            `json:"icon_emoji,omitempty"`
+	Text            string            `json:"text"`
+	Attachments     []SlackAttachment `json:"attachments,omitempty"`
+}
+
+// SlackAttachment only wraps blocks to show the color bar of the status.
+type SlackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+type SlackBlock struct {
+	Type     string        `json:"type"`
+	Text     *SlackText    `json:"text,omitempty"`
+	Fields   []SlackText   `json:"fields,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type SlackButton struct {
+	Type     string    `json:"type"`
+	Text     SlackText `json:"text"`
+	URL      string    `json:"url,omitempty"`
+	ActionID string    `json:"action_id,omitempty"`
+	Value    string    `json:"value,omitempty"`
+	Style    string    `json:"style,omitempty"`
+}
+
+func plainText(s string) SlackText {
+	return SlackText{Type: "plain_text", Text: s, Emoji: true}
+}
+
+func mrkdwn(s string) SlackText {
+	return SlackText{Type: "mrkdwn", Text: s}
+}
+
+func field(title, value string) SlackText {
+	return mrkdwn(fmt.Sprintf("*%s*\n%s", title, value))
+}
+
+func linkButton(id, title, url string) SlackButton {
+	return SlackButton{Type: "button", Text: plainText(title), URL: url, ActionID: id}
+}
+
+// RenderSlackMessage composes the message of b for Slack.
+func RenderSlackMessage(b BuildEvent, c *SlackConfig) SlackMessage {
+	tmpl := c.messageTemplate(b.Status)
+	params := newMessageParams(b, c)
+	text, err := tmpl.renderText(params)
+	if err != nil {
+		defaultLogger.Error(err, "Failed to render the message text of "+b.Status)
+	}
+	// Slack rejects blank headers, which templates such as {{with .PullRequest}} render for some builds.
+	if err != nil || strings.TrimSpace(text) == "" {
+		text, _ = c.defaultMessageTemplate().renderText(params)
+	}
+	header := plainText(truncateText(text, maxHeaderText))
+
+	var fields []SlackText
+	if len(tmpl.Fields) > 0 {
+		fields, err = tmpl.renderFields(params)
+		if err != nil {
+			defaultLogger.Error(err, "Failed to render fields of "+b.Status)
+		}
+	}
+	if len(tmpl.Fields) == 0 || err != nil {
+		fields = builtinFields(b, c)
+	}
+
+	contexts := []interface{}{
+		mrkdwn(fmt.Sprintf(c.translate("Project: `%s`"), b.ProjectID)),
+	}
+	if name := b.TriggerName(); name != "" {
+		contexts = append(contexts, mrkdwn(fmt.Sprintf(c.translate("Trigger: `%s`"), name)))
+	}
+	if d := b.QueueDuration(); d > 0 {
+		contexts = append(contexts, mrkdwn(fmt.Sprintf(c.translate("Queued: %s"), formatDuration(d))))
+	}
+	if d := b.RunDuration(); d > 0 {
+		contexts = append(contexts, mrkdwn(fmt.Sprintf(c.translate("Ran: %s"), formatDuration(d))))
+	}
+	switch {
+	case !b.FinishTime.IsZero():
+		contexts = append(contexts, mrkdwn(fmt.Sprintf(c.translate("Finished at %s"), c.timestamp(b.FinishTime))))
+	case !b.StartTime.IsZero():
+		contexts = append(contexts, mrkdwn(fmt.Sprintf(c.translate("Started at %s"), c.timestamp(b.StartTime))))
+	}
+
+	buttons := []interface{}{
+		linkButton("build_logs", c.translate("Build Logs"), b.LogURL),
+	}
+	if b.IsSuccess() && b.IsDeploy(c) {
+		for i, u := range b.AppURLs(c) {
+			buttons = append(buttons, linkButton(fmt.Sprintf("app_url_%d", i), u.Title, u.URL))
+		}
+	}
+	buttons = append(buttons, buildActionButtons(b, c)...)
+
+	blocks := []SlackBlock{
+		{Type: "header", Text: &header},
+	}
+	// A section block needs at least one field.
+	if len(fields) > 0 {
+		blocks = append(blocks, SlackBlock{Type: "section", Fields: fields})
+	}
+	if c.StepTimeline && (b.Status == "FAILURE" || b.Status == "TIMEOUT") {
+		if tl := b.StepTimeline(); tl != "" {
+			text := mrkdwn(fmt.Sprintf("```\n%s\n```", tl))
+			blocks = append(blocks, SlackBlock{Type: "section", Text: &text})
+		}
+	}
+	if len(b.LogExcerpt) > 0 {
+		text := mrkdwn(logExcerptText(b.LogExcerpt))
+		blocks = append(blocks, SlackBlock{Type: "section", Text: &text})
+	}
+	blocks = append(blocks,
+		SlackBlock{Type: "context", Elements: contexts},
+		SlackBlock{Type: "actions", Elements: buttons},
+	)
+
+	return SlackMessage{
+		Username:  "Cloud Build",
+		IconEmoji: ":cloudbuild:",
+		Text:      text,
+		Attachments: []SlackAttachment{
+			{Color: c.SlackStatus(b.Status).Color, Blocks: blocks},
+		},
+	}
+}
+
+// builtinFields are fields of messages unless templates of the config file define them.
+func builtinFields(b BuildEvent, c *SlackConfig) []SlackText {
+	repo := b.Repository(c)
+	fields := []SlackText{
+		field(c.translate("Status"), fmt.Sprintf("%s %s", c.SlackStatus(b.Status).Icon, b.Status)),
+	}
+	switch {
+	case b.Branch() != "":
+		fields = append(fields, field(c.translate("Branch"), fmt.Sprintf("<%s|%s>", repo.BranchURL(b.Branch()), b.Branch())))
+	case b.GitTag() != "":
+		fields = append(fields, field(c.translate("Git Tag"), b.GitTag()))
+	case b.Origin() != "":
+		fields = append(fields, field(c.translate("Origin"), b.Origin()))
+	}
+	if sha := b.CommitSHA(); sha != "" {
+		fields = append(fields, field(c.translate("Commit"), fmt.Sprintf("<%s|%s>", repo.CommitURL(sha), b.ShortSHA())))
+	}
+	if pr := b.PullRequest(); pr != "" {
+		s := b.Substitutions
+		text := fmt.Sprintf("#%s (%s → %s)", pr, s.HeadBranch, s.BaseBranch)
+		if u := repo.PullRequestURL(pr); u != "" {
+			text = fmt.Sprintf("<%s|#%s> (%s → %s)", u, pr, s.HeadBranch, s.BaseBranch)
+		}
+		fields = append(fields, field(c.translate("Pull Request"), text))
+	}
+	if s := b.FailedStep(); s != nil {
+		text := fmt.Sprintf("`%s` %s", s.Name, s.Status)
+		if s.ID != "" {
+			text = fmt.Sprintf("`%s` (%s) %s", s.ID, s.Name, s.Status)
+		}
+		if d := b.StepDuration(s); d > 0 {
+			text = fmt.Sprintf(c.translate("%s after %s"), text, formatDuration(d))
+		}
+		fields = append(fields, field(c.translate("Failed Step"), text))
+	}
+	if text := streakText(b, c); text != "" {
+		fields = append(fields, field(c.translate("Streak"), text))
+	}
+	if b.Tags != nil && len(*b.Tags) > 0 {
+		fields = append(fields, field(c.translate("Tag"), []string(*b.Tags)[0]))
+	}
+	return fields
+}
+
+// streakText tells whether b breaks, keeps breaking or fixes its branch.
+func streakText(b BuildEvent, c *SlackConfig) string {
+	s := b.Streak
+	switch {
+	case s == nil:
+		return ""
+	case s.IsFixed():
+		return fmt.Sprintf(c.translate("Fixed after %s"), formatDuration(s.FixedAfter))
+	case !s.IsBroken():
+		return ""
+	case s.BrokenSince == b.ID:
+		return c.translate("Broken by this build")
+	}
+	id := s.BrokenSince
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	url := buildConsoleURL(b.ProjectID, s.BrokenSince)
+	return fmt.Sprintf(c.translate("Broken since build <%s|%s> (%d failures)"), url, id, s.Failures)
+}
+
+// maxSectionText is the limit of text in a section block of Slack.
+const maxSectionText = 3000
+
+// maxHeaderText is the limit of characters in a header block of Slack.
+const maxHeaderText = 150
+
+// truncateText cuts s to n characters with an ellipsis, because Slack rejects longer texts of blocks.
+func truncateText(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// logExcerptText quotes the last lines of the log which fit in a section block.
+func logExcerptText(lines []string) string {
+	const quote = "```"
+	size := len(quote)*2 + 2
+	i := len(lines)
+	for ; i > 0; i-- {
+		size += len(lines[i-1]) + 1
+		if size > maxSectionText {
+			break
+		}
+	}
+	excerpt := strings.Replace(strings.Join(lines[i:], "\n"), quote, "'''", -1)
+	return fmt.Sprintf("%s\n%s\n%s", quote, excerpt, quote)
+}
@@ -0,0 +1,206 @@
+package gcf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func assertGolden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(got); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(b), string(want)); diff != "" {
+		t.Errorf("%s differs from the golden file: (-got +want;\n%s)", name, diff)
+	}
+}
+
+func TestRenderSlackMessage(t *testing.T) {
+	sub := &BuildSubstitutions{
+		BranchName:  "feature/slack",
+		CommitSHA:   "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		ShortSHA:    "9f3b1e2",
+		TriggerName: "deploy-on-push",
+	}
+	pr := &BuildSubstitutions{
+		BranchName: "feature/slack",
+		CommitSHA:  "9f3b1e2c4d5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c",
+		PRNumber:   "128",
+		HeadBranch: "feature/slack",
+		BaseBranch: "master",
+	}
+	tests := []struct {
+		status string
+		tags   *BuildTags
+		sub    *BuildSubstitutions
+	}{
+		{"SUCCESS", &BuildTags{"deploy-default-service"}, sub},
+		{"FAILURE", &BuildTags{"deploy-admin-service"}, pr},
+		{"INTERNAL_ERROR", &BuildTags{"test"}, nil},
+		{"TIMEOUT", &BuildTags{"test"}, sub},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.status, func(t *testing.T) {
+			t.Parallel()
+			e := BuildEvent{
+				ID:        "c2a5a1fb-3f5e-4a6b-9d2c-0e1a7c3b5f77",
+				ProjectID: "nomos-sms",
+				Status:    tt.status,
+				LogURL:    "https://console.cloud.google.com/gcr/builds/c2a5a1fb-3f5e-4a6b-9d2c-0e1a7c3b5f77?project=nomos-sms",
+				Source: &BuildSource{
+					RepoSource: &BuildRepoSource{BranchName: "feature/slack"},
+				},
+				Tags:          tt.tags,
+				Substitutions: tt.sub,
+			}
+			got := RenderSlackMessage(e, &SlackConfig{ProjectID: "nomos-sms"})
+			assertGolden(t, "slack_"+tt.status, got)
+		})
+	}
+}
+
+func loadBuildEvent(t *testing.T, name string) BuildEvent {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := BuildEvent{}
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestRenderSlackMessage_fixtures(t *testing.T) {
+	for _, name := range []string{"build_failure", "build_timeout", "build_manual", "build_connected"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			e := loadBuildEvent(t, name)
+			got := RenderSlackMessage(e, &SlackConfig{ProjectID: "nomos-sms", StepTimeline: true})
+			assertGolden(t, "slack_"+name, got)
+		})
+	}
+}
+
+func TestRenderSlackMessage_japanese(t *testing.T) {
+	c := &SlackConfig{ProjectID: "nomos-sms", StepTimeline: true, Language: Japanese, TimeZone: "Asia/Tokyo"}
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	e := loadBuildEvent(t, "build_failure")
+	e.Streak = &BuildStreak{Changed: true, Failures: 1, BrokenSince: e.ID, BrokenAt: e.FinishTime}
+	got := RenderSlackMessage(e, c)
+	assertGolden(t, "slack_build_failure_ja", got)
+}
+
+func TestStreakText(t *testing.T) {
+	tests := []struct {
+		name   string
+		streak *BuildStreak
+		want   string
+	}{
+		{"No history", nil, ""},
+		{"Passing", &BuildStreak{}, ""},
+		{"Broken", &BuildStreak{Changed: true, Failures: 1, BrokenSince: "build-id"}, "Broken by this build"},
+		{
+			"Still broken",
+			&BuildStreak{Failures: 3, BrokenSince: "0a1b2c3d-4e5f"},
+			"Broken since build <https://console.cloud.google.com/cloud-build/builds/0a1b2c3d-4e5f?project=nomos-sms|0a1b2c3d> (3 failures)",
+		},
+		{"Fixed", &BuildStreak{Changed: true, FixedAfter: 2 * time.Hour}, "Fixed after 2h0m0s"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := BuildEvent{ID: "build-id", ProjectID: "nomos-sms", Streak: tt.streak}
+			if got := streakText(b, &SlackConfig{}); got != tt.want {
+				t.Errorf("streakText() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSlackMessage_logExcerpt(t *testing.T) {
+	e := loadBuildEvent(t, "build_failure")
+	lines, err := readLogExcerpt(context.Background(), &DirLogStorage{Dir: "testdata/logs"}, e, 5, defaultRedactPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.LogExcerpt = lines
+	got := RenderSlackMessage(e, &SlackConfig{ProjectID: "nomos-sms"})
+	assertGolden(t, "slack_build_failure_log", got)
+}
+
+func TestLogExcerptText(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"Quote lines", []string{"a", "```b```"}, "```\na\n'''b'''\n```"},
+		{"Drop first lines over the limit", []string{"first", long, long, long[:990]}, "```\n" + long + "\n" + long[:990] + "\n```"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := logExcerptText(tt.lines); got != tt.want {
+				t.Errorf("logExcerptText() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSlackMessage_longHeader(t *testing.T) {
+	m := &MessageTemplate{Text: `{{.Service}} {{range .Tags}}{{.}} {{end}}ビルド {{.ID}} が完了しました。デプロイされたアプリを確認してください。`}
+	if err := m.compile("SUCCESS"); err != nil {
+		t.Fatal(err)
+	}
+	c := &SlackConfig{ProjectID: "nomos-sms", File: FileConfig{Messages: map[string]*MessageTemplate{"SUCCESS": m}}}
+	e := BuildEvent{
+		ID:     "5d8f0a3e-7b21-4c4e-9a6f-2e1d3c4b5a69",
+		Status: "SUCCESS",
+		Tags:   &BuildTags{"deploy-default-service", "deploy-admin-service", "deploy-api-service", "deploy-worker-service"},
+	}
+
+	got := RenderSlackMessage(e, c)
+	header := got.Attachments[0].Blocks[0].Text.Text
+	if n := len([]rune(header)); n != maxHeaderText || !strings.HasSuffix(header, "…") {
+		t.Errorf("RenderSlackMessage() header = %v (%d characters), want %d characters with an ellipsis", header, n, maxHeaderText)
+	}
+	if !strings.HasPrefix(got.Text, strings.TrimSuffix(header, "…")) || !strings.HasSuffix(got.Text, "確認してください。") {
+		t.Errorf("RenderSlackMessage().Text = %v, want the whole text", got.Text)
+	}
+}
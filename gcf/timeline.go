@@ -0,0 +1,85 @@
+package gcf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	timelineWidth      = 20
+	timelineLabelWidth = 24
+)
+
+// QueueDuration returns how long the build waited to start.
+func (e BuildEvent) QueueDuration() time.Duration {
+	if e.CreateTime.IsZero() || e.StartTime.IsZero() || e.StartTime.Before(e.CreateTime) {
+		return 0
+	}
+	return e.StartTime.Sub(e.CreateTime)
+}
+
+// RunDuration returns how long the build ran, or 0 until it finishes.
+func (e BuildEvent) RunDuration() time.Duration {
+	if e.StartTime.IsZero() || e.FinishTime.IsZero() || e.FinishTime.Before(e.StartTime) {
+		return 0
+	}
+	return e.FinishTime.Sub(e.StartTime)
+}
+
+// StepTimeline draws when each step ran in the build as a text bar chart like
+//
+//	go-mod-vendor            ██··················     38s SUCCESS
+//	deploy-notify-slack      ·············███████   1m23s FAILURE
+//
+// It returns an empty string if the build hasn't finished.
+func (e BuildEvent) StepTimeline() string {
+	total := e.RunDuration()
+	if total <= 0 || len(e.Steps) == 0 {
+		return ""
+	}
+
+	lines := make([]string, len(e.Steps))
+	for i := range e.Steps {
+		s := &e.Steps[i]
+		label := s.ID
+		if label == "" {
+			label = s.Name
+		}
+		if len(label) > timelineLabelWidth {
+			label = label[:timelineLabelWidth-1] + "…"
+		}
+
+		bar := strings.Repeat("·", timelineWidth)
+		d := e.StepDuration(s)
+		if d > 0 {
+			start := int(s.Timing.StartTime.Sub(e.StartTime) * timelineWidth / total)
+			length := int(d * timelineWidth / total)
+			// Steps can be longer than the build by gaps of clocks.
+			if length < 1 {
+				length = 1
+			}
+			if length > timelineWidth {
+				length = timelineWidth
+			}
+			if start < 0 {
+				start = 0
+			}
+			if start+length > timelineWidth {
+				start = timelineWidth - length
+			}
+			bar = strings.Repeat("·", start) + strings.Repeat("█", length) +
+				strings.Repeat("·", timelineWidth-start-length)
+		}
+
+		lines[i] = fmt.Sprintf("%-*s %s %7s %s", timelineLabelWidth, label, bar, formatDuration(d), s.Status)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
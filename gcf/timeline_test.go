@@ -0,0 +1,56 @@
+package gcf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildEvent_Durations(t *testing.T) {
+	e := loadBuildEvent(t, "build_failure")
+	if got, want := e.QueueDuration(), 4444555*time.Microsecond; got != want {
+		t.Errorf("BuildEvent.QueueDuration() = %v, want %v", got, want)
+	}
+	if got, want := e.RunDuration(), 270222222*time.Microsecond; got != want {
+		t.Errorf("BuildEvent.RunDuration() = %v, want %v", got, want)
+	}
+
+	running := BuildEvent{CreateTime: e.CreateTime, StartTime: e.StartTime}
+	if got := running.RunDuration(); got != 0 {
+		t.Errorf("BuildEvent.RunDuration() of a running build = %v, want 0", got)
+	}
+	if got := running.StepTimeline(); got != "" {
+		t.Errorf("BuildEvent.StepTimeline() of a running build = %q, want empty", got)
+	}
+}
+
+func TestBuildEvent_StepTimeline(t *testing.T) {
+	e := loadBuildEvent(t, "build_timeout")
+	want := strings.Join([]string{
+		"go-mod-vendor            █···················     40s SUCCESS",
+		"deploy-bakcup-firestore  ·██████████████████·   9m18s TIMEOUT",
+		"deploy-notify-slack      ····················       - CANCELLED",
+	}, "\n")
+	if got := e.StepTimeline(); got != want {
+		t.Errorf("BuildEvent.StepTimeline() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildEvent_StepTimeline_outOfBuild(t *testing.T) {
+	start := time.Date(2019, 2, 14, 1, 0, 0, 0, time.UTC)
+	e := BuildEvent{
+		StartTime:  start,
+		FinishTime: start.Add(5 * time.Minute),
+		Steps: []BuildStep{
+			{ID: "started-before", Status: "SUCCESS", Timing: &TimeSpan{StartTime: start.Add(-time.Minute), EndTime: start.Add(5 * time.Minute)}},
+			{ID: "ended-after", Status: "FAILURE", Timing: &TimeSpan{StartTime: start.Add(time.Minute), EndTime: start.Add(9 * time.Minute)}},
+		},
+	}
+	want := strings.Join([]string{
+		"started-before           ████████████████████    6m0s SUCCESS",
+		"ended-after              ████████████████████    8m0s FAILURE",
+	}, "\n")
+	if got := e.StepTimeline(); got != want {
+		t.Errorf("BuildEvent.StepTimeline() =\n%s\nwant\n%s", got, want)
+	}
+}